@@ -0,0 +1,22 @@
+package app
+
+import (
+	"context"
+	"forum/models"
+)
+
+type contextKey string
+
+const userContextKey = contextKey("user")
+
+// ContextWithUser returns a copy of ctx carrying the signed-in user.
+func ContextWithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// UserFromContext returns the user stored by ContextWithUser, or nil for
+// anonymous requests.
+func UserFromContext(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userContextKey).(*models.User)
+	return user
+}
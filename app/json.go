@@ -0,0 +1,62 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// ErrorResponse is the body of every JSON error response.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	// Fields maps the request fields that failed validation to the reason.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// JSON writes v as the response body with the given status.
+func (app *Application) JSON(w http.ResponseWriter, status int, v any) {
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		app.JSONServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// JSONError writes an error response with the given status and message.
+func (app *Application) JSONError(w http.ResponseWriter, status int, message string) {
+	app.JSON(w, status, ErrorResponse{ErrorDetail{Status: status, Message: message}})
+}
+
+// JSONClientError is the JSON counterpart of ClientError.
+func (app *Application) JSONClientError(w http.ResponseWriter, status int) {
+	app.JSONError(w, status, http.StatusText(status))
+}
+
+// JSONFieldErrors answers a request whose fields did not validate.
+func (app *Application) JSONFieldErrors(w http.ResponseWriter, fields map[string]string) {
+	status := http.StatusUnprocessableEntity
+	app.JSON(w, status, ErrorResponse{ErrorDetail{Status: status, Message: "Some fields are invalid", Fields: fields}})
+}
+
+// JSONServerError logs err like ServerError but keeps the trace out of the
+// response.
+func (app *Application) JSONServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.ErrorLog.Output(2, trace)
+
+	status := http.StatusInternalServerError
+	body, _ := json.Marshal(ErrorResponse{ErrorDetail{Status: status, Message: http.StatusText(status)}})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"forum/pkg/cookie"
 	"html/template"
 	"log"
 )
@@ -8,6 +9,7 @@ import (
 type Application struct {
 	ErrorLog      *log.Logger
 	InfoLog       *log.Logger
+	Flash         *cookie.FlashStore
 	templateCache map[string]*template.Template
 	debug         bool
 	// snippets       models.SnippetModelInterface
@@ -17,10 +19,11 @@ type Application struct {
 	// sessionManager *scs.SessionManager
 }
 
-func New(infoLog, errorLog *log.Logger, templateCache map[string]*template.Template) *Application {
+func New(infoLog, errorLog *log.Logger, templateCache map[string]*template.Template, flash *cookie.FlashStore) *Application {
 	return &Application{
 		ErrorLog:      errorLog,
 		InfoLog:       infoLog,
+		Flash:         flash,
 		templateCache: templateCache,
 		debug:         true,
 	}
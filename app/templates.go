@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"forum/models"
-	"forum/pkg/cookie"
+	"forum/pkg/csrf"
 	"forum/ui"
 	"html/template"
 	"io/fs"
@@ -83,15 +83,12 @@ func (app *Application) Render(w http.ResponseWriter, status int, page string, d
 }
 
 func (app *Application) NewTemplateData(r *http.Request) *models.TemplateData {
+	user := UserFromContext(r.Context())
 	return &models.TemplateData{
-		CurrentYear: time.Now().Year(),
-		//Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		//CSRFToken:       nosurf.Token(r),
+		CurrentYear:     time.Now().Year(),
+		Flashes:         app.Flash.Pop(r),
+		IsAuthenticated: user != nil,
+		CurrentUser:     user,
+		CSRFToken:       csrf.Token(r),
 	}
 }
-
-func (app *Application) isAuthenticated(r *http.Request) bool {
-	cookie := cookie.GetSessionCookie(r)
-	return cookie != nil && cookie.Value != ""
-}
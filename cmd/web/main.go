@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"forum/app"
 	"forum/internal/config"
 	"forum/internal/handlers"
 	"forum/internal/repo"
 	"forum/internal/service"
+	"forum/pkg/cookie"
+	"forum/pkg/mailer"
+	"forum/pkg/media"
+	"forum/pkg/migrate"
 	"log"
 	"net/http"
 	"os"
@@ -22,20 +29,53 @@ func main() {
 
 	cfg := config.MustLoad()
 
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "migrate" {
+			errLog.Fatalf("unknown command %q", args[0])
+		}
+		if err := runMigrate(os.Stdout, cfg.StoragePath, args[1:]); err != nil {
+			errLog.Fatal(err)
+		}
+		return
+	}
+
 	tc, err := app.NewTemplateCache()
 
 	if err != nil {
 		errLog.Fatal(err)
 	}
 
-	app := app.New(infoLog, errLog, tc)
+	app := app.New(infoLog, errLog, tc, cookie.NewFlashStore([]byte(cfg.Secret)))
 
 	r, err := repo.New(cfg.StoragePath)
 	if err != nil {
+		if errors.Is(err, migrate.ErrBehind) {
+			errLog.Fatalf("%v; run \"migrate up\" first", err)
+		}
 		log.Fatal(err)
 	}
 
-	s := service.New(r)
+	m, err := newMailer(cfg)
+	if err != nil {
+		errLog.Fatal(err)
+	}
+
+	store, err := media.NewDisk(cfg.MediaDir)
+	if err != nil {
+		errLog.Fatal(err)
+	}
+
+	s := service.New(r, cfg, m, store)
+
+	if cfg.AdminEmail != "" {
+		if err := s.EnsureAdmin(cfg.AdminEmail); err != nil {
+			errLog.Printf("could not grant admin to %s: %v", cfg.AdminEmail, err)
+		}
+	}
+
+	go s.RunSessionSweeper(context.Background(), cfg.SessionSweepInterval, errLog)
+	go s.RunMailer(context.Background(), errLog)
+	go s.RunTrashPurger(context.Background(), cfg.TrashPurgeInterval, errLog)
 
 	h := handlers.New(s, app)
 
@@ -52,3 +92,12 @@ func main() {
 	fmt.Println(srv.ListenAndServe())
 
 }
+
+// newMailer sends through SMTP when a relay is configured and otherwise
+// writes messages to a local maildir.
+func newMailer(cfg *config.Config) (mailer.Mailer, error) {
+	if cfg.SMTPHost != "" {
+		return mailer.NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.MailFrom), nil
+	}
+	return mailer.NewFile(cfg.MailDir, cfg.MailFrom)
+}
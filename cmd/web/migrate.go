@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"forum/internal/repo"
+	"io"
+	"strconv"
+)
+
+const migrateUsage = `usage: web [flags] migrate <command>
+
+commands:
+  status    list migrations and whether they are applied
+  up        apply all pending migrations
+  down      revert the newest applied migration
+  to N      migrate up or down to version N`
+
+// runMigrate implements the migrate subcommand on the database at dsn.
+func runMigrate(out io.Writer, dsn string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := repo.NewMigrator(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch {
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d  %-30s %s\n", s.Version, s.Name, state)
+		}
+
+	case args[0] == "up" && len(args) == 1:
+		n, err := m.Up()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", n)
+
+	case args[0] == "down" && len(args) == 1:
+		n, err := m.Down()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "reverted %d migration(s)\n", n)
+
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		n, err := m.To(version)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied or reverted %d migration(s)\n", n)
+
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
@@ -7,3 +7,5 @@ require golang.org/x/crypto v0.20.0
 require github.com/mattn/go-sqlite3 v1.14.22
 
 require github.com/google/uuid v1.6.0
+
+require github.com/lib/pq v1.10.9
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"forum/models"
+	"forum/pkg/cookie"
+	"forum/pkg/validator"
+	"net/http"
+)
+
+const maxUserNameLength = 100
+
+func (h *handler) accountSettings(w http.ResponseWriter, r *http.Request) {
+	form := models.AccountSettingsForm{
+		Name: currentUser(r).Name,
+	}
+	h.renderAccountSettings(w, r, http.StatusOK, form)
+}
+
+func (h *handler) accountSettingsName(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	form := models.AccountSettingsForm{
+		Name: r.FormValue("name"),
+	}
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, maxUserNameLength), "name", "This field is too long")
+	if !form.Valid() {
+		h.renderAccountSettings(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	if err := h.service.UpdateUserName(currentUser(r).ID, form.Name); err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	h.flash(r, cookie.FlashSuccess, "Your name has been updated.")
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (h *handler) accountSettingsEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(r)
+	form := models.AccountSettingsForm{
+		Name:            user.Name,
+		Email:           r.FormValue("email"),
+		CurrentPassword: r.FormValue("currentPassword"),
+	}
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	if !form.Valid() {
+		h.renderAccountSettings(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	err := h.service.RequestEmailChange(user.ID, form.CurrentPassword, form.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+		case errors.Is(err, models.ErrDuplicateEmail):
+			form.AddFieldError("email", "Email address is already in use")
+		default:
+			h.app.ServerError(w, err)
+			return
+		}
+		h.renderAccountSettings(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	h.flash(r, cookie.FlashInfo, "We have sent a confirmation link to "+form.Email+". Your email changes once you follow it.")
+	http.Redirect(w, r, "/account/settings", http.StatusSeeOther)
+}
+
+func (h *handler) confirmEmail(w http.ResponseWriter, r *http.Request) {
+	err := h.service.ConfirmEmailChange(r.URL.Query().Get("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidToken):
+			h.app.ClientError(w, http.StatusBadRequest)
+		case errors.Is(err, models.ErrTokenExpired):
+			h.app.ClientError(w, http.StatusGone)
+		case errors.Is(err, models.ErrDuplicateEmail):
+			h.app.ClientError(w, http.StatusConflict)
+		default:
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	h.flash(r, cookie.FlashSuccess, "Your email has been changed.")
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (h *handler) renderAccountSettings(w http.ResponseWriter, r *http.Request, status int, form models.AccountSettingsForm) {
+	data := h.app.NewTemplateData(r)
+	data.User = currentUser(r)
+	data.Form = form
+	h.app.Render(w, status, "settings.html", data)
+}
@@ -1,17 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"forum/models"
 	"forum/pkg/cookie"
+	"forum/pkg/validator"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 func (h *handler) adminDashboard(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		h.app.ClientError(w, http.StatusForbidden)
-		return
-	}
-
 	users, err := h.service.GetAllUsers()
 	if err != nil {
 		h.app.ServerError(w, err)
@@ -20,50 +19,310 @@ func (h *handler) adminDashboard(w http.ResponseWriter, r *http.Request) {
 
 	data := h.app.NewTemplateData(r)
 	data.Users = users
+	data.Roles = models.Roles
 	h.app.Render(w, http.StatusOK, "admin_dashboard.html", data)
 }
 
 func (h *handler) deleteUser(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		h.app.ClientError(w, http.StatusForbidden)
+	methodResolver(w, r, h.deleteUserGet, h.deleteUserPost)
+}
+
+// deleteUserGet shows what deleting a user would affect and asks the admin
+// to confirm.
+func (h *handler) deleteUserGet(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
 		return
 	}
 
-	userID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	user, footprint, err := h.service.PreviewUserDeletion(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	data := h.app.NewTemplateData(r)
+	data.User = user
+	data.Footprint = footprint
+	h.app.Render(w, http.StatusOK, "admin_delete.html", data)
+}
+
+func (h *handler) deleteUserPost(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.FormValue("id"))
 	if err != nil {
 		h.app.ClientError(w, http.StatusBadRequest)
 		return
 	}
 
-	err = h.service.DeleteUser(userID)
+	err = h.service.DeleteUser(currentUser(r), userID)
 	if err != nil {
-		h.app.ServerError(w, err)
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			h.app.NotFound(w)
+		case errors.Is(err, models.ErrForbidden):
+			h.app.ClientError(w, http.StatusForbidden)
+		case errors.Is(err, models.ErrLastAdmin):
+			h.app.ClientError(w, http.StatusConflict)
+		default:
+			h.app.ServerError(w, err)
+		}
 		return
 	}
 
+	h.flash(r, cookie.FlashSuccess, "The user has been deleted.")
 	http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
 }
 
-//	func (h *handler) isAdmin(r *http.Request) bool {
-//		// логика определения администратора на будуще ежже
-//		cookie := cookie.GetSessionCookie(r)
-//		user, err := h.service.GetUserByToken(cookie.Value)
-//		if err != nil {
-//			return false
-//		}
-//
-//		return user.IsAdmin
-//	}
+func (h *handler) updateUserRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	role, err := models.ParseRole(r.FormValue("role"))
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateUserRole(userID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			h.app.NotFound(w)
+		case errors.Is(err, models.ErrLastAdmin):
+			h.app.ClientError(w, http.StatusConflict)
+		default:
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+}
+
+func (h *handler) adminTrash(w http.ResponseWriter, r *http.Request) {
+	posts, err := h.service.GetDeletedPosts()
+	if err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	data := h.app.NewTemplateData(r)
+	data.Posts = &posts
+	h.app.Render(w, http.StatusOK, "admin_trash.html", data)
+}
+
+func (h *handler) adminTrashRestore(w http.ResponseWriter, r *http.Request) {
+	h.adminTrashAction(w, r, h.service.RestorePost, "The post has been restored.")
+}
+
+func (h *handler) adminTrashPurge(w http.ResponseWriter, r *http.Request) {
+	h.adminTrashAction(w, r, h.service.PurgePost, "The post has been deleted permanently.")
+}
+
+// adminTrashAction applies action to the trashed post named by the "id" form
+// value and returns to the trash.
+func (h *handler) adminTrashAction(w http.ResponseWriter, r *http.Request, action func(postID int) error, done string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err = action(postID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	h.flash(r, cookie.FlashSuccess, done)
+	http.Redirect(w, r, "/admin/trash", http.StatusSeeOther)
+}
+
+func (h *handler) adminCategories(w http.ResponseWriter, r *http.Request) {
+	methodResolver(w, r, h.adminCategoriesGet, h.adminCategoriesPost)
+}
+
+func (h *handler) adminCategoriesGet(w http.ResponseWriter, r *http.Request) {
+	h.renderAdminCategories(w, r, http.StatusOK, models.CategoryForm{})
+}
+
+func (h *handler) adminCategoriesPost(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.parseCategoryForm(w, r)
+	if !ok {
+		return
+	}
+	if !form.Valid() {
+		h.renderAdminCategories(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	_, err := h.service.CreateCategory(form.ToCategory())
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateCategory) {
+			form.AddFieldError("slug", "Slug is already in use")
+			h.renderAdminCategories(w, r, http.StatusUnprocessableEntity, form)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
+
+func (h *handler) renderAdminCategories(w http.ResponseWriter, r *http.Request, status int, form models.CategoryForm) {
+	categories, err := h.service.GetAllCategoryForAdmin()
+	if err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	data := h.app.NewTemplateData(r)
+	data.Categories = categories
+	data.Form = form
+	h.app.Render(w, status, "admin_categories.html", data)
+}
+
+func (h *handler) adminCategoryEdit(w http.ResponseWriter, r *http.Request) {
+	methodResolver(w, r, h.adminCategoryEditGet, h.adminCategoryEditPost)
+}
+
+func (h *handler) adminCategoryEditGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
 
-func (h *handler) isAdmin(r *http.Request) bool {
-	cookie := cookie.GetSessionCookie(r)
-	user, err := h.service.GetUserByToken(cookie.Value)
+	category, err := h.service.GetCategoryByID(id)
 	if err != nil {
-		return false
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
 	}
-	if user.Email == "prostok06@gmail.com" {
-		return true
+
+	data := h.app.NewTemplateData(r)
+	data.Category = category
+	data.Form = models.CategoryForm{
+		ID:          category.ID,
+		Name:        category.Name,
+		Slug:        category.Slug,
+		Description: category.Description,
+		Position:    category.Position,
+	}
+	h.app.Render(w, http.StatusOK, "admin_category_edit.html", data)
+}
+
+func (h *handler) adminCategoryEditPost(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.parseCategoryForm(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := h.service.GetCategoryByID(form.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	if form.Valid() {
+		err = h.service.UpdateCategory(form.ToCategory())
+		if err == nil {
+			http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+			return
+		}
+		if !errors.Is(err, models.ErrDuplicateCategory) {
+			h.app.ServerError(w, err)
+			return
+		}
+		form.AddFieldError("slug", "Slug is already in use")
+	}
+
+	data := h.app.NewTemplateData(r)
+	data.Category = category
+	data.Form = form
+	h.app.Render(w, http.StatusUnprocessableEntity, "admin_category_edit.html", data)
+}
+
+func (h *handler) adminCategoryArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	archived := r.FormValue("archived") == "true"
+
+	if err = h.service.ArchiveCategory(id, archived); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
+
+// parseCategoryForm reads and validates the create/edit category form. It
+// writes a 400 response and returns false when the numeric fields are
+// malformed.
+func (h *handler) parseCategoryForm(w http.ResponseWriter, r *http.Request) (models.CategoryForm, bool) {
+	form := models.CategoryForm{
+		Name:        strings.TrimSpace(r.FormValue("name")),
+		Slug:        strings.TrimSpace(r.FormValue("slug")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+	}
+
+	var err error
+	if form.ID, err = optionalInt(r.FormValue("id")); err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return form, false
+	}
+	if form.Position, err = optionalInt(r.FormValue("position")); err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return form, false
 	}
 
-	return false
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 50), "name", "This field cannot be more than 50 characters long")
+	form.CheckField(form.Slug == "" || form.Slug == models.Slugify(form.Slug), "slug", "Use lowercase letters, digits and dashes only")
+	form.CheckField(form.Slug != "" || models.Slugify(form.Name) != "", "slug", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Description, 255), "description", "This field cannot be more than 255 characters long")
+	return form, true
 }
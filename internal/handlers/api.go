@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"forum/models"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiPrefix is where the JSON API is served. Its routes are listed by
+// apiRoutes and described by openapi.json.
+const apiPrefix = "/api/v1"
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// apiHandlerFunc handles an API request. id is the {id} segment of the
+// route's pattern, and zero for patterns without one.
+type apiHandlerFunc func(w http.ResponseWriter, r *http.Request, id int)
+
+type apiRoute struct {
+	method string
+	// pattern is the path below apiPrefix, as written in openapi.json.
+	pattern string
+	handle  apiHandlerFunc
+}
+
+func (h *handler) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{http.MethodGet, "/openapi.json", withoutID(h.apiDocument)},
+		{http.MethodGet, "/posts", withoutID(h.apiPostList)},
+		{http.MethodPost, "/posts", h.apiRequireAuth(withoutID(h.apiPostCreate))},
+		{http.MethodGet, "/posts/{id}", h.apiPostView},
+		{http.MethodPut, "/posts/{id}", h.apiRequireAuth(h.apiPostUpdate)},
+		{http.MethodDelete, "/posts/{id}", h.apiRequireAuth(h.apiPostDelete)},
+		{http.MethodPost, "/posts/{id}/comments", h.apiRequireAuth(h.apiCommentCreate)},
+		{http.MethodPost, "/posts/{id}/reactions", h.apiRequireAuth(h.apiPostReact)},
+		{http.MethodPost, "/comments/{id}/reactions", h.apiRequireAuth(h.apiCommentReact)},
+		{http.MethodGet, "/categories", withoutID(h.apiCategoryList)},
+		{http.MethodGet, "/users/me", h.apiRequireAuth(withoutID(h.apiCurrentUser))},
+	}
+}
+
+func withoutID(next http.HandlerFunc) apiHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ int) {
+		next(w, r)
+	}
+}
+
+// api dispatches requests below apiPrefix to the route whose pattern and
+// method match. Unknown paths, methods and ids are answered with JSON
+// errors, like every failure of the API.
+func (h *handler) api() http.Handler {
+	routes := h.apiRoutes()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+
+		var allowed []string
+		for _, route := range routes {
+			idStr, ok := matchAPIPattern(route.pattern, path)
+			if !ok {
+				continue
+			}
+			if route.method != r.Method {
+				allowed = append(allowed, route.method)
+				continue
+			}
+
+			var id int
+			if idStr != "" {
+				var err error
+				id, err = strconv.Atoi(idStr)
+				if err != nil || id < 1 {
+					h.app.JSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid id %q", idStr))
+					return
+				}
+			}
+			route.handle(w, r, id)
+			return
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			h.app.JSONClientError(w, http.StatusMethodNotAllowed)
+			return
+		}
+		h.app.JSONClientError(w, http.StatusNotFound)
+	})
+}
+
+// matchAPIPattern reports whether path matches pattern, returning the
+// segment that stands for {id}, if any.
+func matchAPIPattern(pattern, path string) (string, bool) {
+	want, got := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(want) != len(got) {
+		return "", false
+	}
+	var id string
+	for i := range want {
+		switch {
+		case want[i] == "{id}" && got[i] != "":
+			id = got[i]
+		case want[i] != got[i]:
+			return "", false
+		}
+	}
+	return id, true
+}
+
+// isAPIRequest reports whether r is for the JSON API, whose failures must
+// be answered in JSON too.
+func isAPIRequest(r *http.Request) bool {
+	return r.URL.Path == apiPrefix || strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+// apiRequireAuth is requireAuth for the API: anonymous clients get a 401
+// instead of being sent to the login page.
+func (h *handler) apiRequireAuth(next apiHandlerFunc) apiHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, id int) {
+		if currentUser(r) == nil {
+			h.app.JSONError(w, http.StatusUnauthorized, "Sign in to do this")
+			return
+		}
+		next(w, r, id)
+	}
+}
+
+// decodeJSON reads a JSON request body into dst. It answers the request and
+// returns false when the body is not a single JSON object matching dst.
+func (h *handler) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		h.app.JSONError(w, http.StatusUnsupportedMediaType, "The request body must be application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(dst)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after the JSON object")
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.app.JSONClientError(w, http.StatusRequestEntityTooLarge)
+			return false
+		}
+		h.app.JSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// queryInt parses an optional positive integer query parameter, returning
+// fallback when it is absent.
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+func (h *handler) apiDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+type apiCategory struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description,omitempty"`
+}
+
+func newAPICategory(c *models.Category) apiCategory {
+	return apiCategory{ID: c.ID, Name: c.Name, Slug: c.Slug, Description: c.Description}
+}
+
+// apiCategoryList lists the categories posts can be filed under.
+func (h *handler) apiCategoryList(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.service.GetAllCategory()
+	if err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	list := struct {
+		Categories []apiCategory `json:"categories"`
+	}{make([]apiCategory, len(categories))}
+	for i := range categories {
+		list.Categories[i] = newAPICategory(&categories[i])
+	}
+	h.app.JSON(w, http.StatusOK, list)
+}
+
+type apiUser struct {
+	ID      int         `json:"id"`
+	Name    string      `json:"name"`
+	Email   string      `json:"email"`
+	Role    models.Role `json:"role"`
+	Created time.Time   `json:"created"`
+}
+
+func (h *handler) apiCurrentUser(w http.ResponseWriter, r *http.Request) {
+	u := currentUser(r)
+	h.app.JSON(w, http.StatusOK, apiUser{ID: u.ID, Name: u.Name, Email: u.Email, Role: u.Role, Created: u.Created})
+}
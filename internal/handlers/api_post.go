@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"forum/models"
+	"forum/pkg/validator"
+	"net/http"
+	"time"
+)
+
+const (
+	apiPageSize    = 20
+	apiMaxPageSize = 100
+)
+
+// apiPost is a post as the API returns it. See the Post schema of
+// openapi.json.
+type apiPost struct {
+	ID          int           `json:"id"`
+	UserID      int           `json:"user_id"`
+	Author      string        `json:"author"`
+	Title       string        `json:"title"`
+	Content     string        `json:"content"`
+	ContentHTML string        `json:"content_html"`
+	ImageURL    string        `json:"image_url,omitempty"`
+	Created     time.Time     `json:"created"`
+	Updated     *time.Time    `json:"updated,omitempty"`
+	Likes       int           `json:"likes"`
+	Dislikes    int           `json:"dislikes"`
+	Reaction    string        `json:"reaction,omitempty"`
+	Categories  []apiCategory `json:"categories"`
+}
+
+// apiPostDetail is a post along with its comments.
+type apiPostDetail struct {
+	apiPost
+	Comments []apiComment `json:"comments"`
+}
+
+type apiComment struct {
+	ID               int       `json:"id"`
+	PostID           int       `json:"post_id"`
+	ParentID         int       `json:"parent_id,omitempty"`
+	UserID           int       `json:"user_id"`
+	Author           string    `json:"author"`
+	Content          string    `json:"content"`
+	ContentHTML      string    `json:"content_html"`
+	Created          time.Time `json:"created"`
+	Likes            int       `json:"likes"`
+	Dislikes         int       `json:"dislikes"`
+	Reaction         string    `json:"reaction,omitempty"`
+	Depth            int       `json:"depth"`
+	HasHiddenReplies bool      `json:"has_hidden_replies,omitempty"`
+}
+
+type apiPostList struct {
+	Posts   []apiPost `json:"posts"`
+	Page    int       `json:"page"`
+	PerPage int       `json:"per_page"`
+	Pages   int       `json:"pages"`
+}
+
+// apiPostInput is the body of requests creating or replacing a post.
+type apiPostInput struct {
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Categories []int  `json:"categories"`
+}
+
+type apiCommentInput struct {
+	Content  string `json:"content"`
+	ParentID int    `json:"parent_id"`
+}
+
+type apiReactionInput struct {
+	Reaction string `json:"reaction"`
+}
+
+func newAPIPost(p *models.Post) apiPost {
+	post := apiPost{
+		ID:          p.PostID,
+		UserID:      p.UserID,
+		Author:      p.UserName,
+		Title:       p.Title,
+		Content:     p.Content,
+		ContentHTML: string(p.HTML()),
+		ImageURL:    p.ImageURL(),
+		Created:     p.Created,
+		Likes:       p.Like,
+		Dislikes:    p.Dislike,
+		Reaction:    reactionName(p.Reaction),
+		Categories:  make([]apiCategory, len(p.Categories)),
+	}
+	if p.Edited() {
+		updated := p.Updated
+		post.Updated = &updated
+	}
+	for i := range p.Categories {
+		post.Categories[i] = newAPICategory(&p.Categories[i])
+	}
+	return post
+}
+
+func newAPIComment(c *models.Comment) apiComment {
+	return apiComment{
+		ID:               c.CommentID,
+		PostID:           c.PostID,
+		ParentID:         c.ParentID,
+		UserID:           c.UserID,
+		Author:           c.UserName,
+		Content:          c.Content,
+		ContentHTML:      string(c.HTML()),
+		Created:          c.Created,
+		Likes:            c.Like,
+		Dislikes:         c.Dislike,
+		Reaction:         reactionName(c.Reaction),
+		Depth:            c.Depth,
+		HasHiddenReplies: c.HasHiddenReplies,
+	}
+}
+
+// reactionName is the inverse of models.ParseReaction, with "" for no
+// reaction.
+func reactionName(r models.Reaction) string {
+	switch r {
+	case models.ReactionLike:
+		return "like"
+	case models.ReactionDislike:
+		return "dislike"
+	}
+	return ""
+}
+
+// apiPostList lists posts a page at a time. The category (repeatable) and
+// user query parameters narrow the list down to posts in any of the given
+// categories or by the given user.
+func (h *handler) apiPostList(w http.ResponseWriter, r *http.Request) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil {
+		h.app.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	perPage, err := queryInt(r, "per_page", apiPageSize)
+	if err != nil || perPage > apiMaxPageSize {
+		h.app.JSONError(w, http.StatusBadRequest, fmt.Sprintf("per_page must be between 1 and %d", apiMaxPageSize))
+		return
+	}
+	userID, err := queryInt(r, "user", 0)
+	if err != nil {
+		h.app.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	categories, err := ConverCategories(r.URL.Query()["category"])
+	if err != nil {
+		h.app.JSONError(w, http.StatusBadRequest, "category must be a category id")
+		return
+	}
+	if userID != 0 && len(categories) > 0 {
+		h.app.JSONError(w, http.StatusBadRequest, "Filter by category or by user, not both")
+		return
+	}
+
+	var posts *[]models.Post
+	list := apiPostList{Page: page, PerPage: perPage}
+	switch {
+	case len(categories) > 0:
+		posts, err = h.service.GetAllPostByCategories(categories)
+	case userID != 0:
+		posts, err = h.service.GetAllPostByUser(userID)
+	default:
+		list.Pages, err = h.service.GetPageNumber(perPage)
+		if err == nil {
+			posts, err = h.service.GetAllPostPaginated(page, perPage)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCategory) {
+			h.app.JSONError(w, http.StatusBadRequest, "category must be an existing category id")
+		} else {
+			h.app.JSONServerError(w, err)
+		}
+		return
+	}
+
+	shown := *posts
+	if len(categories) > 0 || userID != 0 {
+		// The filtered lists come whole; cut the page out here.
+		list.Pages = (len(shown) + perPage - 1) / perPage
+		start, end := (page-1)*perPage, page*perPage
+		if start > len(shown) {
+			start = len(shown)
+		}
+		if end > len(shown) {
+			end = len(shown)
+		}
+		shown = shown[start:end]
+	}
+
+	if err = h.attachReactions(r, shown); err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	list.Posts = make([]apiPost, len(shown))
+	for i := range shown {
+		list.Posts[i] = newAPIPost(&shown[i])
+	}
+	h.app.JSON(w, http.StatusOK, list)
+}
+
+func (h *handler) apiPostView(w http.ResponseWriter, r *http.Request, postID int) {
+	post, err := h.service.GetPostByID(postID)
+	if err != nil {
+		h.apiServiceError(w, err)
+		return
+	}
+	h.writeAPIPost(w, r, http.StatusOK, post)
+}
+
+// writeAPIPost answers with post and its comments, marked with the current
+// user's reactions.
+func (h *handler) writeAPIPost(w http.ResponseWriter, r *http.Request, status int, post *models.Post) {
+	posts := []models.Post{*post}
+	if err := h.attachReactions(r, posts); err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	if err := h.attachCommentReactions(r, posts[0].Comments); err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+
+	detail := apiPostDetail{
+		apiPost:  newAPIPost(&posts[0]),
+		Comments: make([]apiComment, len(posts[0].Comments)),
+	}
+	for i := range posts[0].Comments {
+		detail.Comments[i] = newAPIComment(&posts[0].Comments[i])
+	}
+	h.app.JSON(w, status, detail)
+}
+
+// validatePostInput checks a post the way the create and edit forms do.
+func validatePostInput(in apiPostInput) map[string]string {
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(in.Title), "title", "This field cannot be blank")
+	v.CheckField(validator.NotBlank(in.Content), "content", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(in.Content, maxPostLength), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxPostLength))
+	v.CheckField(len(in.Categories) > 0, "categories", "Choose at least one category")
+	return v.FieldErrors
+}
+
+func (h *handler) apiPostCreate(w http.ResponseWriter, r *http.Request) {
+	var in apiPostInput
+	if !h.decodeJSON(w, r, &in) {
+		return
+	}
+	if fields := validatePostInput(in); len(fields) > 0 {
+		h.app.JSONFieldErrors(w, fields)
+		return
+	}
+
+	postID, err := h.service.CreatePost(currentUser(r).ID, in.Title, in.Content, in.Categories, nil)
+	if err != nil {
+		h.apiServiceError(w, err)
+		return
+	}
+	post, err := h.service.GetPostByID(postID)
+	if err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/posts/%d", apiPrefix, postID))
+	h.writeAPIPost(w, r, http.StatusCreated, post)
+}
+
+func (h *handler) apiPostUpdate(w http.ResponseWriter, r *http.Request, postID int) {
+	var in apiPostInput
+	if !h.decodeJSON(w, r, &in) {
+		return
+	}
+	if fields := validatePostInput(in); len(fields) > 0 {
+		h.app.JSONFieldErrors(w, fields)
+		return
+	}
+
+	err := h.service.UpdatePost(currentUser(r), postID, in.Title, in.Content, in.Categories)
+	if err != nil {
+		h.apiServiceError(w, err)
+		return
+	}
+	post, err := h.service.GetPostByID(postID)
+	if err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	h.writeAPIPost(w, r, http.StatusOK, post)
+}
+
+func (h *handler) apiPostDelete(w http.ResponseWriter, r *http.Request, postID int) {
+	if err := h.service.DeletePost(currentUser(r), postID); err != nil {
+		h.apiServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiPostReact toggles the current user's reaction to a post, as the like
+// and dislike buttons do, and answers with the post's new counts.
+func (h *handler) apiPostReact(w http.ResponseWriter, r *http.Request, postID int) {
+	var in apiReactionInput
+	if !h.decodeJSON(w, r, &in) {
+		return
+	}
+	reaction, err := models.ParseReaction(in.Reaction)
+	if err != nil {
+		h.app.JSONFieldErrors(w, map[string]string{"reaction": `This field must be "like" or "dislike"`})
+		return
+	}
+
+	if err = h.service.ReactToPost(postID, currentUser(r).ID, reaction); err != nil {
+		h.apiServiceError(w, err)
+		return
+	}
+	post, err := h.service.GetPostByID(postID)
+	if err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	h.writeAPIPost(w, r, http.StatusOK, post)
+}
+
+func (h *handler) apiCommentCreate(w http.ResponseWriter, r *http.Request, postID int) {
+	var in apiCommentInput
+	if !h.decodeJSON(w, r, &in) {
+		return
+	}
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(in.Content), "content", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(in.Content, maxCommentLength), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxCommentLength))
+	v.CheckField(in.ParentID >= 0, "parent_id", "This field must be a comment id")
+	if !v.Valid() {
+		h.app.JSONFieldErrors(w, v.FieldErrors)
+		return
+	}
+
+	commentID, err := h.service.CreateComment(postID, currentUser(r).ID, in.ParentID, in.Content)
+	if err != nil {
+		h.apiServiceError(w, err)
+		return
+	}
+	comment, err := h.service.GetCommentByID(commentID)
+	if err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/posts/%d", apiPrefix, postID))
+	h.app.JSON(w, http.StatusCreated, newAPIComment(comment))
+}
+
+// apiCommentReact is apiPostReact for comments.
+func (h *handler) apiCommentReact(w http.ResponseWriter, r *http.Request, commentID int) {
+	var in apiReactionInput
+	if !h.decodeJSON(w, r, &in) {
+		return
+	}
+	reaction, err := models.ParseReaction(in.Reaction)
+	if err != nil {
+		h.app.JSONFieldErrors(w, map[string]string{"reaction": `This field must be "like" or "dislike"`})
+		return
+	}
+
+	if err = h.service.ReactToComment(commentID, currentUser(r).ID, reaction); err != nil {
+		h.apiServiceError(w, err)
+		return
+	}
+	comment, err := h.service.GetCommentByID(commentID)
+	if err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	comments := []models.Comment{*comment}
+	if err = h.attachCommentReactions(r, comments); err != nil {
+		h.app.JSONServerError(w, err)
+		return
+	}
+	h.app.JSON(w, http.StatusOK, newAPIComment(&comments[0]))
+}
+
+// apiServiceError answers with the status matching an error of the service
+// layer.
+func (h *handler) apiServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		h.app.JSONClientError(w, http.StatusNotFound)
+	case errors.Is(err, models.ErrForbidden):
+		h.app.JSONClientError(w, http.StatusForbidden)
+	case errors.Is(err, models.ErrInvalidCategory):
+		h.app.JSONFieldErrors(w, map[string]string{"categories": "Please choose an existing category"})
+	default:
+		h.app.JSONServerError(w, err)
+	}
+}
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"forum/app"
+	"forum/pkg/csrf"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestAPIDocument checks openapi.json against the routes the API serves.
+func TestAPIDocument(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPIDocument, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var documented, served []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	for _, route := range (&handler{}).apiRoutes() {
+		served = append(served, route.method+" "+route.pattern)
+	}
+	sort.Strings(documented)
+	sort.Strings(served)
+	if strings.Join(documented, "\n") != strings.Join(served, "\n") {
+		t.Errorf("documented operations:\n%s\nwant the served routes:\n%s", strings.Join(documented, "\n"), strings.Join(served, "\n"))
+	}
+
+	var tree any
+	if err := json.Unmarshal(openAPIDocument, &tree); err != nil {
+		t.Fatal(err)
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok && !resolves(tree, ref) {
+				t.Errorf("unresolved $ref %s", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(tree)
+}
+
+// resolves reports whether ref, a local JSON pointer like
+// "#/components/schemas/Post", names a node of doc.
+func resolves(doc any, ref string) bool {
+	if !strings.HasPrefix(ref, "#/") {
+		return false
+	}
+	node := doc
+	for _, name := range strings.Split(ref[2:], "/") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		if node, ok = m[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeBody decodes a JSON response body, failing the test if it is not.
+func decodeBody(t *testing.T, body string, v any) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+}
+
+func TestAPIPostList(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+
+	tests := []struct {
+		name      string
+		url       string
+		wantCode  int
+		wantPosts int
+		wantPages int
+	}{
+		{"Default", "/api/v1/posts", http.StatusOK, testPosts, 1},
+		{"Last page", "/api/v1/posts?per_page=5&page=3", http.StatusOK, testPosts - 10, 3},
+		{"Past the end", "/api/v1/posts?per_page=5&page=4", http.StatusOK, 0, 3},
+		{"Category", fmt.Sprintf("/api/v1/posts?category=%d", categorySports), http.StatusOK, testPosts / 2, 1},
+		{"Category page", fmt.Sprintf("/api/v1/posts?category=%d&per_page=4&page=2", categorySports), http.StatusOK, 2, 2},
+		{"User", fmt.Sprintf("/api/v1/posts?user=%d", ta.alice), http.StatusOK, testPosts, 1},
+		{"Posts of nobody", fmt.Sprintf("/api/v1/posts?user=%d", ta.admin), http.StatusOK, 0, 0},
+		{"Wrong page", "/api/v1/posts?page=fdsafdas", http.StatusBadRequest, 0, 0},
+		{"Negative page", "/api/v1/posts?page=-43", http.StatusBadRequest, 0, 0},
+		{"Page too large", "/api/v1/posts?per_page=101", http.StatusBadRequest, 0, 0},
+		{"Unknown category", "/api/v1/posts?category=1000", http.StatusBadRequest, 0, 0},
+		{"Category and user", fmt.Sprintf("/api/v1/posts?category=1&user=%d", ta.alice), http.StatusBadRequest, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tc.url)
+			if code != tc.wantCode {
+				t.Fatalf("got %d; want %d\n%s", code, tc.wantCode, body)
+			}
+			if got := header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("got Content-Type %q; want application/json", got)
+			}
+
+			if code != http.StatusOK {
+				var resp app.ErrorResponse
+				decodeBody(t, body, &resp)
+				if resp.Error.Status != code || resp.Error.Message == "" {
+					t.Errorf("got error %+v; want status %d and a message", resp.Error, code)
+				}
+				return
+			}
+			var list apiPostList
+			decodeBody(t, body, &list)
+			if len(list.Posts) != tc.wantPosts || list.Pages != tc.wantPages {
+				t.Errorf("got %d posts of %d pages; want %d of %d", len(list.Posts), list.Pages, tc.wantPosts, tc.wantPages)
+			}
+			if list.Posts == nil {
+				t.Error("got posts null; want a list")
+			}
+		})
+	}
+}
+
+func TestAPIPostView(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+	}{
+		{"Valid ID", fmt.Sprintf("/api/v1/posts/%d", ta.posts[1]), http.StatusOK},
+		{"Non-existent ID", fmt.Sprintf("/api/v1/posts/%d", ta.posts[len(ta.posts)-1]+1), http.StatusNotFound},
+		{"Negative ID", "/api/v1/posts/-1", http.StatusBadRequest},
+		{"String ID", "/api/v1/posts/bruh", http.StatusBadRequest},
+		{"Unknown path", "/api/v1/sdfadsf", http.StatusNotFound},
+		{"Unknown action", fmt.Sprintf("/api/v1/posts/%d/edit", ta.posts[1]), http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tc.url)
+			if code != tc.wantCode {
+				t.Fatalf("got %d; want %d\n%s", code, tc.wantCode, body)
+			}
+			if code != http.StatusOK {
+				var resp app.ErrorResponse
+				decodeBody(t, body, &resp)
+				if resp.Error.Status != code {
+					t.Errorf("got error status %d; want %d", resp.Error.Status, code)
+				}
+				return
+			}
+
+			var post apiPostDetail
+			decodeBody(t, body, &post)
+			if post.ID != ta.posts[1] || post.Title != "Post 2" || post.ContentHTML != "<p>Content of post 2</p>" {
+				t.Errorf("got post %d %q %q; want %d %q", post.ID, post.Title, post.ContentHTML, ta.posts[1], "Post 2")
+			}
+			if len(post.Categories) != 1 || post.Categories[0].ID != categorySports {
+				t.Errorf("got categories %+v; want sports", post.Categories)
+			}
+			if post.Comments == nil {
+				t.Error("got comments null; want a list")
+			}
+		})
+	}
+}
+
+func TestAPIMethodNotAllowed(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	code, header, _ := ts.sendJSON(t, http.MethodPatch, "/api/v1/posts", nil)
+	if code != http.StatusMethodNotAllowed {
+		t.Errorf("got %d; want %d", code, http.StatusMethodNotAllowed)
+	}
+	if got, want := header.Get("Allow"), "GET, POST"; got != want {
+		t.Errorf("got Allow %q; want %q", got, want)
+	}
+}
+
+func TestAPIRequireAuth(t *testing.T) {
+	ta := newTestApplication(t)
+	anonymous := newTestServer(t, ta)
+	alice := newTestServer(t, ta)
+	alice.login(t, aliceEmail, testPassword)
+
+	input := apiPostInput{Title: "Title", Content: "Content", Categories: []int{categoryTechnology}}
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		method   string
+		url      string
+		body     any
+		wantCode int
+	}{
+		{"Anonymous create", anonymous, http.MethodPost, "/api/v1/posts", input, http.StatusUnauthorized},
+		{"Anonymous delete", anonymous, http.MethodDelete, fmt.Sprintf("/api/v1/posts/%d", ta.posts[0]), nil, http.StatusUnauthorized},
+		{"Anonymous current user", anonymous, http.MethodGet, "/api/v1/users/me", nil, http.StatusUnauthorized},
+		{"Current user", alice, http.MethodGet, "/api/v1/users/me", nil, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, body := tc.ts.sendJSON(t, tc.method, tc.url, tc.body)
+			if code != tc.wantCode {
+				t.Errorf("got %d; want %d\n%s", code, tc.wantCode, body)
+			}
+		})
+	}
+
+	t.Run("Current user body", func(t *testing.T) {
+		_, _, body := alice.get(t, "/api/v1/users/me")
+		var u apiUser
+		decodeBody(t, body, &u)
+		if u.ID != ta.alice || u.Email != aliceEmail || u.Role != "user" {
+			t.Errorf("got %+v; want alice", u)
+		}
+	})
+
+	t.Run("Missing CSRF token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/v1/posts/%d", alice.URL, ta.posts[0]), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := alice.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, header, body := readResponse(t, resp)
+		if code != http.StatusForbidden || header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %d %s; want a JSON %d", code, header.Get("Content-Type"), http.StatusForbidden)
+		}
+		var e app.ErrorResponse
+		decodeBody(t, body, &e)
+	})
+}
+
+func TestAPIPostWrite(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+	ts.login(t, aliceEmail, testPassword)
+
+	// Creating.
+	code, header, body := ts.sendJSON(t, http.MethodPost, "/api/v1/posts", apiPostInput{
+		Title: "New post", Content: "Some *emphasis*", Categories: []int{categoryTechnology, categorySports},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("create: got %d; want %d\n%s", code, http.StatusCreated, body)
+	}
+	var post apiPostDetail
+	decodeBody(t, body, &post)
+	if want := fmt.Sprintf("/api/v1/posts/%d", post.ID); header.Get("Location") != want {
+		t.Errorf("create: got Location %q; want %q", header.Get("Location"), want)
+	}
+	if post.UserID != ta.alice || post.ContentHTML != "<p>Some <em>emphasis</em></p>\n" || len(post.Categories) != 2 {
+		t.Errorf("create: got %+v", post)
+	}
+	postURL := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+
+	invalid := []struct {
+		name       string
+		body       any
+		wantCode   int
+		wantFields []string
+	}{
+		{"Blank fields", apiPostInput{}, http.StatusUnprocessableEntity, []string{"title", "content", "categories"}},
+		{"Unknown category", apiPostInput{Title: "T", Content: "C", Categories: []int{1000}}, http.StatusUnprocessableEntity, []string{"categories"}},
+		{"Content too long", apiPostInput{Title: "T", Content: strings.Repeat("x", maxPostLength+1), Categories: []int{categoryTechnology}}, http.StatusUnprocessableEntity, []string{"content"}},
+		{"Unknown field", map[string]any{"title": "T", "content": "C", "categories": []int{1}, "pinned": true}, http.StatusBadRequest, nil},
+		{"Wrong type", map[string]any{"title": 1}, http.StatusBadRequest, nil},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, body := ts.sendJSON(t, http.MethodPost, "/api/v1/posts", tc.body)
+			if code != tc.wantCode {
+				t.Fatalf("got %d; want %d\n%s", code, tc.wantCode, body)
+			}
+			var resp app.ErrorResponse
+			decodeBody(t, body, &resp)
+			for _, field := range tc.wantFields {
+				if resp.Error.Fields[field] == "" {
+					t.Errorf("got fields %v; want an error for %s", resp.Error.Fields, field)
+				}
+			}
+		})
+	}
+
+	t.Run("Form body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/posts", strings.NewReader("title=T&content=C&categories=1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(csrf.HeaderName, ts.csrfToken(t))
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code, _, _ := readResponse(t, resp); code != http.StatusUnsupportedMediaType {
+			t.Errorf("got %d; want %d", code, http.StatusUnsupportedMediaType)
+		}
+	})
+
+	// Editing.
+	code, _, body = ts.sendJSON(t, http.MethodPut, postURL, apiPostInput{
+		Title: "Edited post", Content: "Edited", Categories: []int{categorySports},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("update: got %d; want %d\n%s", code, http.StatusOK, body)
+	}
+	decodeBody(t, body, &post)
+	if post.Title != "Edited post" || post.Updated == nil || len(post.Categories) != 1 {
+		t.Errorf("update: got %+v", post)
+	}
+
+	adminPost, err := ta.repo.CreatePost(ta.admin, "Admin post", "Content", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, _ = ts.sendJSON(t, http.MethodPut, fmt.Sprintf("/api/v1/posts/%d", adminPost), apiPostInput{
+		Title: "Mine now", Content: "Edited", Categories: []int{categorySports},
+	})
+	if code != http.StatusForbidden {
+		t.Errorf("update someone else's post: got %d; want %d", code, http.StatusForbidden)
+	}
+
+	// Reacting and commenting.
+	reactions := []struct {
+		reaction     string
+		wantLikes    int
+		wantReaction string
+	}{
+		{"like", 1, "like"},
+		{"like", 0, ""},
+		{"dislike", 0, "dislike"},
+	}
+	for _, tc := range reactions {
+		code, _, body = ts.sendJSON(t, http.MethodPost, postURL+"/reactions", apiReactionInput{tc.reaction})
+		if code != http.StatusOK {
+			t.Fatalf("react %s: got %d; want %d\n%s", tc.reaction, code, http.StatusOK, body)
+		}
+		var got apiPostDetail
+		decodeBody(t, body, &got)
+		if got.Likes != tc.wantLikes || got.Reaction != tc.wantReaction {
+			t.Errorf("react %s: got %d likes and reaction %q; want %d and %q", tc.reaction, got.Likes, got.Reaction, tc.wantLikes, tc.wantReaction)
+		}
+	}
+	if code, _, _ = ts.sendJSON(t, http.MethodPost, postURL+"/reactions", apiReactionInput{"love"}); code != http.StatusUnprocessableEntity {
+		t.Errorf("react love: got %d; want %d", code, http.StatusUnprocessableEntity)
+	}
+
+	code, _, body = ts.sendJSON(t, http.MethodPost, postURL+"/comments", apiCommentInput{Content: "First"})
+	if code != http.StatusCreated {
+		t.Fatalf("comment: got %d; want %d\n%s", code, http.StatusCreated, body)
+	}
+	var comment apiComment
+	decodeBody(t, body, &comment)
+	code, _, body = ts.sendJSON(t, http.MethodPost, postURL+"/comments", apiCommentInput{Content: "Reply", ParentID: comment.ID})
+	if code != http.StatusCreated {
+		t.Fatalf("reply: got %d; want %d\n%s", code, http.StatusCreated, body)
+	}
+	if code, _, _ = ts.sendJSON(t, http.MethodPost, fmt.Sprintf("/api/v1/posts/%d/comments", ta.posts[0]), apiCommentInput{Content: "Reply", ParentID: comment.ID}); code != http.StatusNotFound {
+		t.Errorf("reply on another post: got %d; want %d", code, http.StatusNotFound)
+	}
+
+	code, _, body = ts.sendJSON(t, http.MethodPost, fmt.Sprintf("/api/v1/comments/%d/reactions", comment.ID), apiReactionInput{"dislike"})
+	if code != http.StatusOK {
+		t.Fatalf("react to comment: got %d; want %d\n%s", code, http.StatusOK, body)
+	}
+	decodeBody(t, body, &comment)
+	if comment.Dislikes != 1 || comment.Reaction != "dislike" {
+		t.Errorf("react to comment: got %d dislikes and reaction %q", comment.Dislikes, comment.Reaction)
+	}
+
+	_, _, body = ts.get(t, postURL)
+	post = apiPostDetail{}
+	decodeBody(t, body, &post)
+	if len(post.Comments) != 2 || post.Comments[1].ParentID != comment.ID || post.Comments[1].Depth != 1 {
+		t.Errorf("got comments %+v; want a comment and its reply", post.Comments)
+	}
+
+	// Deleting.
+	if code, _, body = ts.sendJSON(t, http.MethodDelete, postURL, nil); code != http.StatusNoContent {
+		t.Fatalf("delete: got %d; want %d\n%s", code, http.StatusNoContent, body)
+	}
+	if code, _, _ = ts.get(t, postURL); code != http.StatusNotFound {
+		t.Errorf("get deleted: got %d; want %d", code, http.StatusNotFound)
+	}
+	if code, _, _ = ts.sendJSON(t, http.MethodPost, fmt.Sprintf("/api/v1/comments/%d/reactions", comment.ID), apiReactionInput{"like"}); code != http.StatusNotFound {
+		t.Errorf("react to a comment of a deleted post: got %d; want %d", code, http.StatusNotFound)
+	}
+	if code, _, _ = ts.sendJSON(t, http.MethodDelete, fmt.Sprintf("/api/v1/posts/%d", adminPost), nil); code != http.StatusForbidden {
+		t.Errorf("delete someone else's post: got %d; want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestAPICategoryList(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	code, _, body := ts.get(t, "/api/v1/categories")
+	if code != http.StatusOK {
+		t.Fatalf("got %d; want %d", code, http.StatusOK)
+	}
+	var list struct {
+		Categories []apiCategory `json:"categories"`
+	}
+	decodeBody(t, body, &list)
+	if len(list.Categories) < 4 || list.Categories[0].Slug == "" {
+		t.Errorf("got %+v; want the seeded categories", list.Categories)
+	}
+}
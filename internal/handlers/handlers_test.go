@@ -1,51 +1,26 @@
 package handlers
 
 import (
-	"forum/app"
-	"forum/internal/repo"
-	"forum/internal/service"
-	"io/ioutil"
-	"log"
+	"bytes"
+	"errors"
+	"fmt"
+	"forum/models"
+	"forum/pkg/token"
+	"image"
+	"image/png"
+	"mime/multipart"
 	"net/http"
-	"net/http/httptest"
 	"net/url"
-	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
-func newTestServer(t *testing.T) *httptest.Server {
-	infoLog := log.New(os.Stdout, "\u001b[32mINFO\t\u001b[0m", log.Ldate|log.Ltime)
-	errLog := log.New(os.Stdout, "\u001b[31mERROR\t\u001b[0m", log.Ldate|log.Ltime|log.Lshortfile)
-
-	// cfg := config.MustLoad()
-
-	tc, err := app.NewTemplateCache()
-
-	if err != nil {
-		errLog.Fatal(err)
-	}
-
-	app := app.New(infoLog, errLog, tc)
-
-	r, err := repo.New("../../data/storage.db")
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	s := service.New(r)
-
-	h := New(s, app)
-
-	srv := httptest.NewServer(h.Routes())
-	return srv
-}
-
 func TestPostView(t *testing.T) {
-
-	ts := newTestServer(t)
-
-	defer ts.Close()
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
 
 	tests := []struct {
 		name     string
@@ -55,12 +30,13 @@ func TestPostView(t *testing.T) {
 	}{
 		{
 			name:     "Valid ID",
-			url:      "/post/1",
+			url:      fmt.Sprintf("/post/%d", ta.posts[0]),
 			wantCode: http.StatusOK,
+			wantBody: "<p>Content of post 1</p>",
 		},
 		{
 			name:     "Non-existent ID",
-			url:      "/post/100",
+			url:      fmt.Sprintf("/post/%d", ta.posts[len(ta.posts)-1]+1),
 			wantCode: http.StatusNotFound,
 		},
 		{
@@ -86,31 +62,24 @@ func TestPostView(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			resp, err := http.Get(ts.URL + tt.url) // Use ts.URL
-			if err != nil {
-				t.Fatal(err)
-			}
-			defer resp.Body.Close()
+			code, _, body := ts.get(t, tt.url)
 
-			if resp.StatusCode != tt.wantCode {
-				t.Errorf("got %d; want %d", resp.StatusCode, tt.wantCode)
+			if code != tt.wantCode {
+				t.Errorf("got %d; want %d", code, tt.wantCode)
 			}
-
-			if tt.wantBody != "" {
-				body, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					t.Fatal(err)
-				}
-				if !strings.Contains(string(body), tt.wantBody) {
-					t.Errorf("unexpected body: got %s; want %s", body, tt.wantBody)
-				}
+			if tt.wantBody != "" && !strings.Contains(body, tt.wantBody) {
+				t.Errorf("unexpected body: got %s; want %s", body, tt.wantBody)
 			}
 		})
 	}
 }
+
 func TestHomeGet(t *testing.T) {
-	ts := newTestServer(t)
-	defer ts.Close()
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+
+	firstPage := fmt.Sprintf(`href="/post/%d"`, ta.posts[0])
+	lastPage := fmt.Sprintf(`href="/post/%d"`, ta.posts[2*pageSize])
 
 	tests := []struct {
 		name       string
@@ -118,132 +87,647 @@ func TestHomeGet(t *testing.T) {
 		wantCode   int
 		wantInBody string
 	}{
-
 		{
 			name:     "No page",
 			url:      "/fdsafads",
 			wantCode: http.StatusNotFound,
 		},
 		{
-			name:     "pagination Page",
+			name:       "First page",
+			url:        "/",
+			wantCode:   http.StatusOK,
+			wantInBody: firstPage,
+		},
+		{
+			name:       "pagination Page",
+			url:        "/?page=3",
+			wantCode:   http.StatusOK,
+			wantInBody: lastPage,
+		},
+		{
+			name:     "Page past the end",
 			url:      "/?page=4",
-			wantCode: http.StatusOK,
+			wantCode: http.StatusNotFound,
 		},
+		// A page number that does not parse shows the first page.
 		{
-			name:     "Wrong pagination Page",
-			url:      "/?page=fdsafdas",
-			wantCode: http.StatusBadRequest,
+			name:       "Wrong pagination Page",
+			url:        "/?page=fdsafdas",
+			wantCode:   http.StatusOK,
+			wantInBody: firstPage,
 		},
 		{
-			name:     "Wrong pagination Page",
-			url:      "/?page=0.32",
-			wantCode: http.StatusBadRequest,
+			name:       "Wrong pagination Page",
+			url:        "/?page=0.32",
+			wantCode:   http.StatusOK,
+			wantInBody: firstPage,
 		},
 		{
-			name:     "Wrong pagination Page",
-			url:      "/?page=-43",
-			wantCode: http.StatusBadRequest,
+			name:       "Wrong pagination Page",
+			url:        "/?page=-43",
+			wantCode:   http.StatusOK,
+			wantInBody: firstPage,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tc.url)
+
+			if code != tc.wantCode {
+				t.Errorf("got %d; want %d", code, tc.wantCode)
+			}
+			if tc.wantInBody != "" && !strings.Contains(body, tc.wantInBody) {
+				t.Errorf("unexpected body: got %s; want %s", body, tc.wantInBody)
+			}
+		})
+	}
+}
+
+func TestHomePost(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+
+	tests := []struct {
+		name          string
+		categories    []string
+		wantCode      int
+		wantInBody    string
+		wantNotInBody string
+	}{
+		{
+			name:          "Valid Submission",
+			categories:    []string{fmt.Sprint(categorySports)},
+			wantCode:      http.StatusOK,
+			wantInBody:    fmt.Sprintf(`href="/post/%d"`, ta.posts[1]),
+			wantNotInBody: fmt.Sprintf(`href="/post/%d"`, ta.posts[0]),
+		},
+		{
+			name:       "Valid Submission",
+			categories: []string{fmt.Sprint(categoryTechnology), fmt.Sprint(categorySports)},
+			wantCode:   http.StatusOK,
+			wantInBody: fmt.Sprintf(`href="/post/%d"`, ta.posts[0]),
+		},
+		{
+			name:       "Invalid Submission",
+			categories: []string{"1000"},
+			wantCode:   http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid Submission",
+			categories: []string{"dsafasdf"},
+			wantCode:   http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid Submission",
+			categories: []string{},
+			wantCode:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{}
+			for _, cat := range tc.categories {
+				form.Add("categories", cat)
+			}
+
+			code, _, body := ts.postForm(t, "/", form)
+
+			if code != tc.wantCode {
+				t.Errorf("got %d; want %d", code, tc.wantCode)
+			}
+			if tc.wantInBody != "" && !strings.Contains(body, tc.wantInBody) {
+				t.Errorf("body does not contain %s", tc.wantInBody)
+			}
+			if tc.wantNotInBody != "" && strings.Contains(body, tc.wantNotInBody) {
+				t.Errorf("body contains %s", tc.wantNotInBody)
+			}
+		})
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+	client := ts.Client()
+
+	tests := []struct {
+		name         string
+		url          string
+		cookie       *http.Cookie
+		wantLocation string
+	}{
+		{
+			name:         "Anonymous account",
+			url:          "/account",
+			wantLocation: "/login?next=%2Faccount",
+		},
+		{
+			name:         "Anonymous create post",
+			url:          "/post/create",
+			wantLocation: "/login?next=%2Fpost%2Fcreate",
+		},
+		{
+			name:         "Unknown session",
+			url:          "/account/view",
+			cookie:       &http.Cookie{Name: "session_id", Value: "not-a-session"},
+			wantLocation: "/login?next=%2Faccount%2Fview",
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			resp, err := http.Get(ts.URL + tc.url)
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tc.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.cookie != nil {
+				req.AddCookie(tc.cookie)
+			}
+
+			resp, err := client.Do(req)
 			if err != nil {
 				t.Fatal(err)
 			}
 			defer resp.Body.Close()
 
-			if resp.StatusCode != tc.wantCode {
-				t.Errorf("got %d; want %d", resp.StatusCode, tc.wantCode)
+			if resp.StatusCode != http.StatusSeeOther {
+				t.Errorf("got %d; want %d", resp.StatusCode, http.StatusSeeOther)
+			}
+			if got := resp.Header.Get("Location"); got != tc.wantLocation {
+				t.Errorf("got location %q; want %q", got, tc.wantLocation)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	ta := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		{
+			name:     "Anonymous",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "User",
+			email:    aliceEmail,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Admin",
+			email:    adminEmail,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t, ta)
+			if tc.email != "" {
+				ts.login(t, tc.email, testPassword)
 			}
 
-			if tc.wantInBody != "" {
-				body, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
+			code, _, _ := ts.get(t, "/admin/dashboard")
+			if code != tc.wantCode {
+				t.Errorf("got %d; want %d", code, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestAdminDeleteUser(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+	ts.login(t, adminEmail, testPassword)
+
+	tests := []struct {
+		name     string
+		id       int
+		wantCode int
+	}{
+		{
+			name:     "Placeholder",
+			id:       models.DeletedUserID,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Self",
+			id:       ta.admin,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Unknown",
+			id:       ta.admin + 100,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "User",
+			id:       ta.alice,
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Set("id", strconv.Itoa(tc.id))
+			code, _, _ := ts.postForm(t, "/admin/delete", form)
+			if code != tc.wantCode {
+				t.Errorf("got %d; want %d", code, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestAdminUpdateRole(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+	ts.login(t, adminEmail, testPassword)
+
+	// The cases run in order and share the users' roles.
+	tests := []struct {
+		name     string
+		id       int
+		role     models.Role
+		wantCode int
+	}{
+		{
+			name:     "Last admin",
+			id:       ta.admin,
+			role:     models.RoleModerator,
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:     "Unknown",
+			id:       ta.admin + 100,
+			role:     models.RoleUser,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid role",
+			id:       ta.alice,
+			role:     "owner",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Promote",
+			id:       ta.alice,
+			role:     models.RoleAdmin,
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Demote with another admin left",
+			id:       ta.admin,
+			role:     models.RoleUser,
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Set("id", strconv.Itoa(tc.id))
+			form.Set("role", string(tc.role))
+			code, _, _ := ts.postForm(t, "/admin/role", form)
+			if code != tc.wantCode {
+				t.Errorf("got %d; want %d", code, tc.wantCode)
+			}
+		})
+	}
+
+	if u, err := ta.repo.GetUserByID(ta.admin); err != nil || u.Role != models.RoleUser {
+		t.Errorf("got %+v, %v; want the former admin demoted", u, err)
+	}
+}
+
+func TestConfirmEmail(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		email    string
+		expires  time.Time
+		token    string
+		wantCode int
+	}{
+		{
+			name:     "Missing",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Unknown",
+			token:    "unknown",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Expired",
+			email:    "alice@example.org",
+			expires:  now.Add(-time.Minute),
+			token:    "expired",
+			wantCode: http.StatusGone,
+		},
+		{
+			name:     "Taken",
+			email:    adminEmail,
+			expires:  now.Add(time.Hour),
+			token:    "taken",
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:     "Valid",
+			email:    "alice@example.org",
+			expires:  now.Add(time.Hour),
+			token:    "valid",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.email != "" {
+				if err := ta.repo.SetPendingEmail(ta.alice, tc.email, token.Hash(tc.token), tc.expires); err != nil {
 					t.Fatal(err)
 				}
-				if !strings.Contains(string(body), tc.wantInBody) {
-					t.Errorf("unexpected body: got %s; want %s", body, tc.wantInBody)
-				}
+			}
+			code, _, _ := ts.get(t, "/account/email/confirm?token="+url.QueryEscape(tc.token))
+			if code != tc.wantCode {
+				t.Errorf("got %d; want %d", code, tc.wantCode)
 			}
 		})
 	}
 }
-func TestHomePost(t *testing.T) {
-	ts := newTestServer(t)
-	defer ts.Close()
-
-	infoLog := log.New(os.Stdout, "\u001b[32mINFO\t\u001b[0m", log.Ldate|log.Ltime)
-	errLog := log.New(os.Stdout, "\u001b[31mERROR\t\u001b[0m", log.Ldate|log.Ltime|log.Lshortfile)
 
-	// cfg := config.MustLoad()
+func TestEmailChangeLink(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+	ts.login(t, aliceEmail, testPassword)
 
-	tc, err := app.NewTemplateCache()
+	form := url.Values{}
+	form.Set("email", "alice@example.org")
+	form.Set("currentPassword", testPassword)
+	if code, _, body := ts.postForm(t, "/account/settings/email", form); code != http.StatusSeeOther {
+		t.Fatalf("got %d; want %d\n%s", code, http.StatusSeeOther, body)
+	}
 
+	mail, err := ta.repo.GetDueMail(time.Now(), 10)
 	if err != nil {
-		errLog.Fatal(err)
+		t.Fatal(err)
+	}
+	var link string
+	for _, m := range mail {
+		if m.Recipient == "alice@example.org" {
+			link = regexp.MustCompile(`/account/email/confirm\?token=\S+`).FindString(m.Body)
+		}
+	}
+	if link == "" {
+		t.Fatalf("no confirmation link mailed to the new address in %+v", mail)
 	}
+	secret := strings.TrimPrefix(link, "/account/email/confirm?token=")
 
-	app := app.New(infoLog, errLog, tc)
+	// Only the hash of the token is stored.
+	if _, err := ta.repo.GetUserByActivationToken(secret); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("got %v looking up the token itself; want %v", err, models.ErrNoRecord)
+	}
 
-	r, err := repo.New("../../data/storage.db")
-	if err != nil {
-		log.Fatal(err)
+	if code, _, _ := ts.get(t, link); code != http.StatusSeeOther {
+		t.Errorf("got %d; want %d", code, http.StatusSeeOther)
 	}
+	if u, err := ta.repo.GetUserByID(ta.alice); err != nil || u.Email != "alice@example.org" {
+		t.Errorf("got %+v, %v; want the new address", u, err)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
 
-	s := service.New(r)
+	// Without a cookie jar, each request carries only the cookies it is
+	// given.
+	client := &http.Client{CheckRedirect: ts.Client().CheckRedirect}
+
+	resp, err := client.Get(ts.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
 
-	h := New(s, app)
+	var token string
+	for _, c := range resp.Cookies() {
+		if c.Name == "csrf_token" {
+			token = c.Value
+		}
+	}
+	if token == "" {
+		t.Fatal("no csrf_token cookie issued")
+	}
 
 	tests := []struct {
-		name       string
-		categories []string
-		wantCode   int
+		name      string
+		cookie    string
+		formToken string
+		wantCode  int
 	}{
 		{
-			name:       "Valid Submission",
-			categories: []string{"1", "2"},
-			wantCode:   http.StatusOK,
+			name:     "Missing token",
+			wantCode: http.StatusForbidden,
 		},
 		{
-			name:       "Invalid Submission",
-			categories: []string{"1000"},
-			wantCode:   http.StatusBadRequest,
+			name:      "Token without cookie",
+			formToken: token,
+			wantCode:  http.StatusForbidden,
 		},
 		{
-			name:       "Invalid Submission",
-			categories: []string{"dsafasdf"},
-			wantCode:   http.StatusBadRequest,
+			name:      "Mismatched token",
+			cookie:    token,
+			formToken: "forged",
+			wantCode:  http.StatusForbidden,
 		},
 		{
-			name:       "Invalid Submission",
-			categories: []string{},
-			wantCode:   http.StatusBadRequest,
+			name:      "Valid token",
+			cookie:    token,
+			formToken: token,
+			wantCode:  http.StatusSeeOther,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			form := url.Values{}
-			for _, cat := range tc.categories {
-				form.Add("categories", cat)
+			if tc.formToken != "" {
+				form.Set("csrf_token", tc.formToken)
 			}
 
-			req, err := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/logout", strings.NewReader(form.Encode()))
 			if err != nil {
 				t.Fatal(err)
 			}
 			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tc.cookie})
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.wantCode {
+				t.Errorf("got %d; want %d", resp.StatusCode, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestPostCreateMultipart(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+	ts.login(t, aliceEmail, testPassword)
 
-			rr := httptest.NewRecorder()
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
 
-			h.homePost(rr, req)
+	tests := []struct {
+		name     string
+		token    string
+		image    []byte
+		wantCode int
+	}{
+		{
+			name:     "Missing token",
+			image:    img.Bytes(),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Without image",
+			token:    ts.csrfToken(t),
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "With image",
+			token:    ts.csrfToken(t),
+			image:    img.Bytes(),
+			wantCode: http.StatusSeeOther,
+		},
+	}
 
-			if rr.Code != tc.wantCode {
-				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.wantCode)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fields := map[string]string{
+				"title":      "Multipart post",
+				"content":    "Posted as multipart/form-data",
+				"categories": strconv.Itoa(categoryTechnology),
+			}
+			if tc.token != "" {
+				fields["csrf_token"] = tc.token
+			}
+			for name, value := range fields {
+				if err := mw.WriteField(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fw, err := mw.CreateFormFile("image", "image.png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write(tc.image)
+			if err := mw.Close(); err != nil {
+				t.Fatal(err)
 			}
 
+			resp, err := ts.Client().Post(ts.URL+"/post/create", mw.FormDataContentType(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, header, respBody := readResponse(t, resp)
+			if code != tc.wantCode {
+				t.Fatalf("got %d; want %d\n%s", code, tc.wantCode, respBody)
+			}
+			if code != http.StatusSeeOther {
+				return
+			}
+
+			id, err := strconv.Atoi(strings.TrimPrefix(header.Get("Location"), "/post/"))
+			if err != nil {
+				t.Fatalf("got Location %q; want the new post", header.Get("Location"))
+			}
+			post, err := ta.repo.GetPostByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasImage := post.ImageURL() != ""; hasImage != (tc.image != nil) {
+				t.Errorf("got image %q; want one: %t", post.ImageURL(), tc.image != nil)
+			}
 		})
 	}
 }
+
+func TestPostContentLength(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+	ts.login(t, aliceEmail, testPassword)
+
+	tests := []struct {
+		name     string
+		url      string
+		content  string
+		wantCode int
+	}{
+		{"Create", "/post/create", strings.Repeat("x", maxPostLength+1), http.StatusUnprocessableEntity},
+		{"Preview", "/post/preview", strings.Repeat("x", maxPostLength+1), http.StatusUnprocessableEntity},
+		{"Edit", fmt.Sprintf("/post/%d/edit", ta.posts[0]), strings.Repeat("x", maxPostLength+1), http.StatusUnprocessableEntity},
+		{"Preview at the limit", "/post/preview", strings.Repeat(">", maxPostLength), http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Set("title", "Long post")
+			form.Set("content", tc.content)
+			form.Set("categories", strconv.Itoa(categoryTechnology))
+			code, _, body := ts.postForm(t, tc.url, form)
+			if code != tc.wantCode {
+				t.Fatalf("got %d; want %d", code, tc.wantCode)
+			}
+			if code != http.StatusOK && !strings.Contains(body, "cannot be more than") {
+				t.Errorf("got no error for the content field")
+			}
+		})
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta)
+
+	// The snippet delimiters are stripped from submitted text, so they
+	// cannot unbalance the <mark> elements of the results.
+	_, err := ta.handler.service.CreatePost(ta.alice, "Stray \x03delimiters\x02", "Unbalanced \x02zebra\x03\x03 marks\x02", []int{categoryTechnology}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, body := ts.get(t, "/search?q=zebra")
+	if code != http.StatusOK {
+		t.Fatalf("got %d; want %d", code, http.StatusOK)
+	}
+	if !strings.Contains(body, "<mark>zebra</mark> marks") {
+		t.Errorf("want the term highlighted in\n%s", body)
+	}
+	if opened, closed := strings.Count(body, "<mark>"), strings.Count(body, "</mark>"); opened != closed {
+		t.Errorf("got %d <mark> and %d </mark>", opened, closed)
+	}
+	if strings.ContainsAny(body, "\x02\x03") {
+		t.Errorf("got snippet delimiters in\n%s", body)
+	}
+}
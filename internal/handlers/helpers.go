@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"forum/app"
+	"forum/models"
+	"forum/pkg/cookie"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// currentUser returns the user loaded by the authenticate middleware, or nil
+// for anonymous visitors.
+func currentUser(r *http.Request) *models.User {
+	return app.UserFromContext(r.Context())
+}
+
+// loginURL builds the login address that brings the user back to next once
+// they have signed in.
+func loginURL(next string) string {
+	if next == "" || next == "/" {
+		return "/login"
+	}
+	return "/login?next=" + url.QueryEscape(next)
+}
+
+// safeRedirectPath accepts only local absolute paths, so that a crafted next
+// parameter cannot send the user to another site after login.
+func safeRedirectPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// flash queues a message for the next page the visitor sees.
+func (h *handler) flash(r *http.Request, level cookie.FlashLevel, message string) {
+	h.app.Flash.Put(r, level, message)
+}
+
+// redirectBack sends the client back to the page it came from when that page
+// is on this site, and to fallback otherwise.
+func redirectBack(w http.ResponseWriter, r *http.Request, fallback string) {
+	target := fallback
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+		target = ref.RequestURI()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// optionalInt parses a non-negative integer form value, treating an empty
+// value as zero.
+func optionalInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errors.New("negative value")
+	}
+	return n, nil
+}
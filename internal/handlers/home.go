@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
+	"forum/models"
 	"net/http"
 	"strconv"
 )
@@ -50,6 +51,10 @@ func (h *handler) homeGet(w http.ResponseWriter, r *http.Request) {
 		h.app.ServerError(w, err)
 		return
 	}
+	if err = h.attachReactions(r, *posts); err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
 
 	data.Posts = posts
 	data.CurrentPage = currentPage
@@ -75,9 +80,16 @@ func (h *handler) homePost(w http.ResponseWriter, r *http.Request) {
 	}
 	posts, err := h.service.GetAllPostByCategories(filterCategories)
 	if err != nil {
+		if errors.Is(err, models.ErrInvalidCategory) {
+			h.app.ClientError(w, http.StatusBadRequest)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+	if err = h.attachReactions(r, *posts); err != nil {
 		h.app.ServerError(w, err)
 		return
-
 	}
 	data := h.app.NewTemplateData(r)
 
@@ -100,9 +112,6 @@ func ConverCategories(CategoriesString []string) ([]int, error) {
 		if err != nil {
 			return nil, err
 		}
-		if nb > 10 {
-			return nil, fmt.Errorf("bad request")
-		}
 		categories[i] = nb
 	}
 
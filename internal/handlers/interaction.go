@@ -1 +1,203 @@
-package handlers
\ No newline at end of file
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"forum/models"
+	"forum/pkg/validator"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxCommentLength = 2000
+
+func (h *handler) commentCreate(w http.ResponseWriter, r *http.Request, postID int) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(r)
+	if user == nil {
+		redirectToLogin(w, r)
+		return
+	}
+
+	form := models.CommentForm{
+		Content: r.FormValue("content"),
+	}
+	if parentStr := r.FormValue("parent_id"); parentStr != "" {
+		var err error
+		form.ParentID, err = strconv.Atoi(parentStr)
+		if err != nil || form.ParentID < 1 {
+			h.app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Content, maxCommentLength), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxCommentLength))
+
+	if !form.Valid() {
+		post, err := h.service.GetPostByID(postID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				h.app.NotFound(w)
+			} else {
+				h.app.ServerError(w, err)
+			}
+			return
+		}
+		data := h.app.NewTemplateData(r)
+		data.Post = post
+		data.Form = form
+		h.app.Render(w, http.StatusUnprocessableEntity, "post.html", data)
+		return
+	}
+
+	commentID, err := h.service.CreateComment(postID, user.ID, form.ParentID, form.Content)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/post/%d#comment-%d", postID, commentID), http.StatusSeeOther)
+}
+
+func (h *handler) postReact(w http.ResponseWriter, r *http.Request, postID int) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(r)
+	if user == nil {
+		redirectToLogin(w, r)
+		return
+	}
+
+	reaction, err := models.ParseReaction(r.FormValue("reaction"))
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.ReactToPost(postID, user.ID, reaction)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	redirectBack(w, r, fmt.Sprintf("/post/%d", postID))
+}
+
+// attachReactions marks the posts the current visitor has reacted to.
+func (h *handler) attachReactions(r *http.Request, posts []models.Post) error {
+	user := currentUser(r)
+	if user == nil {
+		return nil
+	}
+
+	postIDs := make([]int, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.PostID
+	}
+	reactions, err := h.service.GetPostReactions(user.ID, postIDs)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Reaction = reactions[posts[i].PostID]
+	}
+	return nil
+}
+
+func (h *handler) comment(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/comment/")
+	idStr, action, _ := strings.Cut(rest, "/")
+	commentID, err := strconv.Atoi(idStr)
+	if err != nil || commentID < 1 {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "react":
+		h.commentReact(w, r, commentID)
+	default:
+		h.app.NotFound(w)
+	}
+}
+
+func (h *handler) commentReact(w http.ResponseWriter, r *http.Request, commentID int) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(r)
+	if user == nil {
+		redirectToLogin(w, r)
+		return
+	}
+
+	reaction, err := models.ParseReaction(r.FormValue("reaction"))
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	comment, err := h.service.GetCommentByID(commentID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	if err = h.service.ReactToComment(comment.CommentID, user.ID, reaction); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	redirectBack(w, r, fmt.Sprintf("/post/%d#comment-%d", comment.PostID, comment.CommentID))
+}
+
+// attachCommentReactions marks the comments the current visitor has reacted to.
+func (h *handler) attachCommentReactions(r *http.Request, comments []models.Comment) error {
+	user := currentUser(r)
+	if user == nil {
+		return nil
+	}
+
+	commentIDs := make([]int, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.CommentID
+	}
+	reactions, err := h.service.GetCommentReactions(user.ID, commentIDs)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Reaction = reactions[comments[i].CommentID]
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// media serves uploaded images. Their names are content-addressed, so a
+// response never goes stale and may be cached for good.
+func (h *handler) media(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/media/")
+	f, err := h.service.OpenMedia(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		h.app.ServerError(w, errors.New("media file is not seekable"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
@@ -1,8 +1,21 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"forum/app"
+	"forum/models"
+	"forum/pkg/cookie"
 	"net/http"
+	"net/url"
+)
+
+const (
+	// maxFormMemory is how much of a multipart form is kept in memory; the
+	// rest is buffered in temporary files.
+	maxFormMemory = 1 << 20
+	// maxFormOverhead allows for the fields sent along with an upload.
+	maxFormOverhead = 1 << 20
 )
 
 // func decorator(){
@@ -19,3 +32,129 @@ func methodResolver(w http.ResponseWriter, r *http.Request, get, post func(w htt
 		//error
 	}
 }
+
+// authenticate resolves the session cookie once per request and stores its
+// owner in the request context. The cookie follows the session's sliding
+// expiry; unknown or expired sessions are treated as anonymous and their
+// cookie is cleared.
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := cookie.GetSessionCookie(r)
+		if c == nil || c.Value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, session, err := h.service.ResolveSession(c.Value)
+		if err != nil {
+			if !errors.Is(err, models.ErrNoRecord) {
+				h.app.ServerError(w, err)
+				return
+			}
+			cookie.ExpireSessionCookie(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+		cookie.SetSessionCookie(w, session.Token, session.ExpTime)
+
+		next.ServeHTTP(w, r.WithContext(app.ContextWithUser(r.Context(), user)))
+	})
+}
+
+// limitBody caps request bodies at the largest image upload plus the fields
+// sent with it. Bodies announced as larger are refused before being read.
+func (h *handler) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := h.service.MaxImageSize() + maxFormOverhead
+		if r.ContentLength > limit {
+			if isAPIRequest(r) {
+				h.app.JSONClientError(w, http.StatusRequestEntityTooLarge)
+			} else {
+				h.app.ClientError(w, http.StatusRequestEntityTooLarge)
+			}
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseForm parses the body of form submissions once, before csrf.Protect
+// looks for the token in it, keeping at most maxFormMemory of a multipart
+// form in memory. Other bodies, such as JSON, are left for the handler.
+func (h *handler) parseForm(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := r.ParseMultipartForm(maxFormMemory)
+		if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			if isAPIRequest(r) {
+				h.app.JSONClientError(w, status)
+			} else {
+				h.app.ClientError(w, status)
+			}
+			return
+		}
+		// The server only removes the temporary files of the request it
+		// created, not of the copies made by middleware.
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAuth lets the request through only for signed-in users. Anonymous
+// visitors are sent to the login page, which brings them back afterwards.
+func (h *handler) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r) == nil {
+			redirectToLogin(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireRole lets the request through only for signed-in users holding at
+// least the given role. Anonymous visitors are sent to the login page.
+func (h *handler) requireRole(role models.Role, next http.HandlerFunc) http.HandlerFunc {
+	return h.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !currentUser(r).Role.AtLeast(role) {
+			h.app.ClientError(w, http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// redirectToLogin sends an anonymous visitor to the login page. Only GET
+// requests are remembered as the place to return to; a form submission is
+// replaced by the page the form was on.
+func redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	next := r.URL.RequestURI()
+	if r.Method != http.MethodGet {
+		next = ""
+		if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host {
+			next = ref.RequestURI()
+		}
+	}
+	http.Redirect(w, r, loginURL(next), http.StatusSeeOther)
+}
+
+// csrfFailure answers requests rejected by csrf.Protect.
+func (h *handler) csrfFailure(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		h.app.JSONError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+		return
+	}
+	h.app.ClientError(w, http.StatusForbidden)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"forum/models"
+	"forum/pkg/cookie"
+	"forum/pkg/validator"
+	"net/http"
+)
+
+const resetLinkSentMessage = "If an account with that email exists, we have sent it a link to reset the password."
+
+func (h *handler) passwordForgot(w http.ResponseWriter, r *http.Request) {
+	methodResolver(w, r, h.passwordForgotGet, h.passwordForgotPost)
+}
+
+func (h *handler) passwordForgotGet(w http.ResponseWriter, r *http.Request) {
+	data := h.app.NewTemplateData(r)
+	data.Form = models.PasswordForgotForm{}
+	h.app.Render(w, http.StatusOK, "password_forgot.html", data)
+}
+
+func (h *handler) passwordForgotPost(w http.ResponseWriter, r *http.Request) {
+	form := models.PasswordForgotForm{
+		Email: r.FormValue("email"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	if !form.Valid() {
+		data := h.app.NewTemplateData(r)
+		data.Form = form
+		h.app.Render(w, http.StatusUnprocessableEntity, "password_forgot.html", data)
+		return
+	}
+
+	if err := h.service.RequestPasswordReset(form.Email); err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	h.flash(r, cookie.FlashInfo, resetLinkSentMessage)
+	http.Redirect(w, r, "/password/forgot", http.StatusSeeOther)
+}
+
+func (h *handler) passwordReset(w http.ResponseWriter, r *http.Request) {
+	methodResolver(w, r, h.passwordResetGet, h.passwordResetPost)
+}
+
+func (h *handler) passwordResetGet(w http.ResponseWriter, r *http.Request) {
+	form := models.PasswordResetForm{
+		Token: r.URL.Query().Get("token"),
+	}
+
+	if err := h.service.CheckPasswordResetToken(form.Token); err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			h.renderInvalidResetLink(w, r, form)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	data := h.app.NewTemplateData(r)
+	data.Form = form
+	h.app.Render(w, http.StatusOK, "password_reset.html", data)
+}
+
+func (h *handler) passwordResetPost(w http.ResponseWriter, r *http.Request) {
+	form := models.PasswordResetForm{
+		Token:                   r.FormValue("token"),
+		NewPassword:             r.FormValue("newPassword"),
+		NewPasswordConfirmation: r.FormValue("newPasswordConfirmation"),
+	}
+
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+	if !form.Valid() {
+		data := h.app.NewTemplateData(r)
+		data.Form = form
+		h.app.Render(w, http.StatusUnprocessableEntity, "password_reset.html", data)
+		return
+	}
+
+	if err := h.service.ResetPassword(form.Token, form.NewPassword); err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			h.renderInvalidResetLink(w, r, form)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	cookie.ExpireSessionCookie(w)
+	h.flash(r, cookie.FlashSuccess, "Your password has been reset. Please log in with the new one.")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (h *handler) renderInvalidResetLink(w http.ResponseWriter, r *http.Request, form models.PasswordResetForm) {
+	form.AddFieldError("token", "This reset link is invalid or has expired.")
+	data := h.app.NewTemplateData(r)
+	data.Form = form
+	h.app.Render(w, http.StatusBadRequest, "password_reset.html", data)
+}
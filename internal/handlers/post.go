@@ -5,12 +5,20 @@ import (
 	"fmt"
 	"forum/models"
 	"forum/pkg/cookie"
+	"forum/pkg/diff"
+	"forum/pkg/markdown"
 	"forum/pkg/validator"
+	"html/template"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
+// maxPostLength bounds the Markdown source of a post, which is rendered on
+// every save and preview.
+const maxPostLength = 20000
+
 func (h *handler) postCreate(w http.ResponseWriter, r *http.Request) {
 	methodResolver(w, r, h.postCreateGet, h.postCreatePost)
 }
@@ -28,6 +36,15 @@ func (h *handler) postCreateGet(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handler) postCreatePost(w http.ResponseWriter, r *http.Request) {
+	image, err := formImage(r)
+	if err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	if image != nil {
+		defer image.Close()
+	}
+
 	form := models.PostForm{
 		Title:            r.FormValue("title"),
 		Content:          r.FormValue("content"),
@@ -36,56 +53,153 @@ func (h *handler) postCreatePost(w http.ResponseWriter, r *http.Request) {
 
 	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
 	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Content, maxPostLength), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxPostLength))
 	form.CheckField(validator.NotSelected(form.CategoriesString), "categories", "This field cannot be selected")
 	form.CheckField(validator.IsError(form.ConverCategories()), "categories", "This field is incoreted")
 
 	if !form.Valid() {
-		data := h.app.NewTemplateData(r)
-		data.Form = form
-		categories, err := h.service.GetAllCategory()
-		if err != nil {
-			h.app.ServerError(w, err)
-		}
-		data.Categories = categories
-		h.app.Render(w, http.StatusUnprocessableEntity, "create.html", data)
+		h.renderPostCreate(w, r, form)
 		return
 	}
-	cookie_ := cookie.GetSessionCookie(r)
-	postID, err := h.service.CreatePost(form.Title, form.Content, cookie_.Value, form.Categories)
+	postID, err := h.service.CreatePost(currentUser(r).ID, form.Title, form.Content, form.Categories, image)
 
 	if err != nil {
-		h.app.ServerError(w, err)
+		switch {
+		case errors.Is(err, models.ErrInvalidCategory):
+			form.AddFieldError("categories", "Please choose an existing category")
+		case errors.Is(err, models.ErrImageTooLarge):
+			form.AddFieldError("image", fmt.Sprintf("The image cannot be larger than %d KB", h.service.MaxImageSize()>>10))
+		case errors.Is(err, models.ErrInvalidImage):
+			form.AddFieldError("image", "Please upload a JPEG, PNG or GIF image")
+		default:
+			h.app.ServerError(w, err)
+			return
+		}
+		h.renderPostCreate(w, r, form)
+		return
 	}
+	h.flash(r, cookie.FlashSuccess, "Your post has been published.")
 	http.Redirect(w, r, fmt.Sprintf("/post/%d", postID), http.StatusSeeOther)
 }
 
-func (h *handler) postView(w http.ResponseWriter, r *http.Request) {
+// postPreview shows the create form again with the content rendered as it
+// will appear once published.
+func (h *handler) postPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	form := models.PostForm{
+		Title:            r.FormValue("title"),
+		Content:          r.FormValue("content"),
+		CategoriesString: r.Form["categories"],
+	}
+	if err := form.ConverCategories(); err != nil {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
 
-	path := r.URL.Path
-	if path[:5] != "/post" {
-		h.app.ClientError(w, http.StatusNotFound)
-		fmt.Println("fdsafasdfasdfadsfads")
+	categories, err := h.service.GetAllCategory()
+	if err != nil {
+		h.app.ServerError(w, err)
 		return
 	}
 
-	idStr := strings.TrimPrefix(path, "/post/")
+	form.CheckField(validator.MaxChars(form.Content, maxPostLength), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxPostLength))
 
-	if idStr == path || idStr == "" {
-		h.app.ClientError(w, http.StatusBadRequest)
+	data := h.app.NewTemplateData(r)
+	data.Form = form
+	data.Categories = categories
+	if !form.Valid() {
+		h.app.Render(w, http.StatusUnprocessableEntity, "create.html", data)
 		return
 	}
+	data.Preview = template.HTML(markdown.Render(form.Content))
+	h.app.Render(w, http.StatusOK, "create.html", data)
+}
 
-	ID, err := strconv.Atoi(idStr)
+func (h *handler) renderPostCreate(w http.ResponseWriter, r *http.Request, form models.PostForm) {
+	categories, err := h.service.GetAllCategory()
 	if err != nil {
-		h.app.ClientError(w, http.StatusBadRequest)
+		h.app.ServerError(w, err)
 		return
 	}
 
-	if ID < 0 {
+	data := h.app.NewTemplateData(r)
+	data.Form = form
+	data.Categories = categories
+	h.app.Render(w, http.StatusUnprocessableEntity, "create.html", data)
+}
+
+// formImage returns the optional "image" file of a multipart form, or nil
+// when none was chosen. The form has already been parsed by parseForm.
+func formImage(r *http.Request) (multipart.File, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// Browsers send an empty part when the file input is left blank.
+	if header.Size == 0 {
+		file.Close()
+		return nil, nil
+	}
+	return file, nil
+}
+
+func (h *handler) post(w http.ResponseWriter, r *http.Request) {
+	postID, action, err := parsePostPath(r.URL.Path)
+	if err != nil {
 		h.app.ClientError(w, http.StatusBadRequest)
 		return
 	}
 
+	switch action {
+	case "":
+		h.postView(w, r, postID)
+	case "comment":
+		h.commentCreate(w, r, postID)
+	case "react":
+		h.postReact(w, r, postID)
+	case "edit":
+		h.postEdit(w, r, postID)
+	case "history":
+		h.postHistory(w, r, postID)
+	case "delete":
+		h.postDelete(w, r, postID)
+	default:
+		h.app.NotFound(w)
+	}
+}
+
+// parsePostPath splits "/post/{id}[/{action}]" into the post id and the
+// optional action segment.
+func parsePostPath(path string) (int, string, error) {
+	rest := strings.TrimPrefix(path, "/post/")
+	if rest == path || rest == "" {
+		return 0, "", fmt.Errorf("invalid post path %q", path)
+	}
+
+	idStr, action, _ := strings.Cut(rest, "/")
+	ID, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, "", err
+	}
+	if ID < 0 {
+		return 0, "", fmt.Errorf("invalid post id %d", ID)
+	}
+	return ID, action, nil
+}
+
+func (h *handler) postView(w http.ResponseWriter, r *http.Request, ID int) {
 	post, err := h.service.GetPostByID(ID)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
@@ -97,6 +211,218 @@ func (h *handler) postView(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := h.app.NewTemplateData(r)
-	data.Post = post
+
+	if threadStr := r.URL.Query().Get("thread"); threadStr != "" {
+		threadID, err := strconv.Atoi(threadStr)
+		if err != nil || threadID < 1 {
+			h.app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		thread, err := h.service.GetCommentThread(ID, threadID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				h.app.NotFound(w)
+			} else {
+				h.app.ServerError(w, err)
+			}
+			return
+		}
+		post.Comments = thread
+		data.ThreadID = threadID
+	}
+
+	posts := []models.Post{*post}
+	if err = h.attachReactions(r, posts); err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+	if err = h.attachCommentReactions(r, posts[0].Comments); err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	data.Post = &posts[0]
+	data.Form = models.CommentForm{}
 	h.app.Render(w, http.StatusOK, "post.html", data)
 }
+
+func (h *handler) postEdit(w http.ResponseWriter, r *http.Request, postID int) {
+	user := currentUser(r)
+	if user == nil {
+		redirectToLogin(w, r)
+		return
+	}
+
+	post, err := h.service.GetPostByID(postID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+	if !post.EditableBy(user) {
+		h.app.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		form := models.PostForm{
+			Title:      post.Title,
+			Content:    post.Content,
+			Categories: models.CategoryIDs(post.Categories),
+		}
+		h.renderPostEdit(w, r, http.StatusOK, post, form)
+	case http.MethodPost:
+		h.postEditPost(w, r, user, post)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) postEditPost(w http.ResponseWriter, r *http.Request, user *models.User, post *models.Post) {
+	form := models.PostForm{
+		Title:            r.FormValue("title"),
+		Content:          r.FormValue("content"),
+		CategoriesString: r.Form["categories"],
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Content, maxPostLength), "content", fmt.Sprintf("This field cannot be more than %d characters long", maxPostLength))
+	form.CheckField(validator.NotSelected(form.CategoriesString), "categories", "This field cannot be selected")
+	form.CheckField(validator.IsError(form.ConverCategories()), "categories", "This field is incoreted")
+
+	if !form.Valid() {
+		h.renderPostEdit(w, r, http.StatusUnprocessableEntity, post, form)
+		return
+	}
+
+	err := h.service.UpdatePost(user, post.PostID, form.Title, form.Content, form.Categories)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidCategory):
+			form.AddFieldError("categories", "Please choose an existing category")
+			h.renderPostEdit(w, r, http.StatusUnprocessableEntity, post, form)
+		case errors.Is(err, models.ErrForbidden):
+			h.app.ClientError(w, http.StatusForbidden)
+		case errors.Is(err, models.ErrNoRecord):
+			h.app.NotFound(w)
+		default:
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	h.flash(r, cookie.FlashSuccess, "Your changes have been saved.")
+	http.Redirect(w, r, fmt.Sprintf("/post/%d", post.PostID), http.StatusSeeOther)
+}
+
+func (h *handler) renderPostEdit(w http.ResponseWriter, r *http.Request, status int, post *models.Post, form models.PostForm) {
+	categories, err := h.service.GetAllCategory()
+	if err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	data := h.app.NewTemplateData(r)
+	data.Post = post
+	data.Form = form
+	data.Categories = categories
+	h.app.Render(w, status, "edit.html", data)
+}
+
+func (h *handler) postDelete(w http.ResponseWriter, r *http.Request, postID int) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(r)
+	if user == nil {
+		redirectToLogin(w, r)
+		return
+	}
+
+	err := h.service.DeletePost(user, postID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrForbidden):
+			h.app.ClientError(w, http.StatusForbidden)
+		case errors.Is(err, models.ErrNoRecord):
+			h.app.NotFound(w)
+		default:
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	h.flash(r, cookie.FlashSuccess, "The post has been deleted.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// postHistory lists the revisions of a post and the line diff between two of
+// them, picked with the "from" and "to" query parameters. By default the
+// latest revision is compared with the one before it.
+func (h *handler) postHistory(w http.ResponseWriter, r *http.Request, postID int) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	post, err := h.service.GetPostByID(postID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+	revisions, err := h.service.GetPostRevisions(postID)
+	if err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	last := len(revisions) - 1
+	from, to := &revisions[last], &revisions[last]
+	if last > 0 {
+		from = &revisions[last-1]
+	}
+	query := r.URL.Query()
+	if query.Has("from") || query.Has("to") {
+		from, to = findRevision(revisions, query.Get("from")), findRevision(revisions, query.Get("to"))
+		if from == nil || to == nil {
+			h.app.NotFound(w)
+			return
+		}
+	}
+
+	data := h.app.NewTemplateData(r)
+	data.Post = post
+	data.Revisions = revisions
+	data.FromRevision = from
+	data.ToRevision = to
+	data.Diff = diff.Lines(from.Text(), to.Text())
+	h.app.Render(w, http.StatusOK, "history.html", data)
+}
+
+// findRevision returns the revision whose id is given as a string, or nil.
+func findRevision(revisions []models.PostRevision, idStr string) *models.PostRevision {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil
+	}
+	for i := range revisions {
+		if revisions[i].ID == id {
+			return &revisions[i]
+		}
+	}
+	return nil
+}
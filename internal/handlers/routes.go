@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"forum/models"
+	"forum/pkg/csrf"
 	"forum/ui"
 	"net/http"
 	"path/filepath"
@@ -14,21 +16,46 @@ func (h *handler) Routes() http.Handler {
 	mux.Handle("/static", http.NotFoundHandler())
 	mux.Handle("/static/", fileServer)
 
+	mux.HandleFunc("/media/", h.media)
+
 	mux.HandleFunc("/", h.home)
-	mux.HandleFunc("/post/", h.postView)
-	mux.HandleFunc("/post/create", h.postCreate)
+	mux.HandleFunc("/post/", h.post)
+	mux.HandleFunc("/post/create", h.requireAuth(h.postCreate))
+	mux.HandleFunc("/post/preview", h.requireAuth(h.postPreview))
+	mux.HandleFunc("/search", h.search)
+	mux.HandleFunc("/comment/", h.comment)
 	mux.HandleFunc("/login", h.login)
 	mux.HandleFunc("/signup", h.signup)
 	mux.HandleFunc("/logout", h.logoutPost)
+	mux.HandleFunc("/password/forgot", h.passwordForgot)
+	mux.HandleFunc("/password/reset", h.passwordReset)
 
-	mux.HandleFunc("/account/view", h.PostByUser)
-	mux.HandleFunc("/account/password", h.UpdateUserPassword)
-	mux.HandleFunc("/account", h.userView)
-	mux.HandleFunc("/admin/dashboard", h.adminDashboard)
-	mux.HandleFunc("/admin/delete", h.deleteUser)
+	mux.HandleFunc("/account/view", h.requireAuth(h.PostByUser))
+	mux.HandleFunc("/account/liked", h.requireAuth(h.likedPosts))
+	mux.HandleFunc("/account/password", h.requireAuth(h.UpdateUserPassword))
+	mux.HandleFunc("/account/settings", h.requireAuth(h.accountSettings))
+	mux.HandleFunc("/account/settings/name", h.requireAuth(h.accountSettingsName))
+	mux.HandleFunc("/account/settings/email", h.requireAuth(h.accountSettingsEmail))
+	mux.HandleFunc("/account/email/confirm", h.confirmEmail)
+	mux.HandleFunc("/account/sessions", h.requireAuth(h.accountSessions))
+	mux.HandleFunc("/account/sessions/revoke", h.requireAuth(h.revokeSession))
+	mux.HandleFunc("/account/sessions/revoke-all", h.requireAuth(h.revokeAllSessions))
+	mux.HandleFunc("/account", h.requireAuth(h.userView))
+	mux.HandleFunc("/admin/dashboard", h.requireRole(models.RoleAdmin, h.adminDashboard))
+	mux.HandleFunc("/admin/delete", h.requireRole(models.RoleAdmin, h.deleteUser))
+	mux.HandleFunc("/admin/role", h.requireRole(models.RoleAdmin, h.updateUserRole))
+	mux.HandleFunc("/admin/trash", h.requireRole(models.RoleAdmin, h.adminTrash))
+	mux.HandleFunc("/admin/trash/restore", h.requireRole(models.RoleAdmin, h.adminTrashRestore))
+	mux.HandleFunc("/admin/trash/purge", h.requireRole(models.RoleAdmin, h.adminTrashPurge))
+	mux.HandleFunc("/admin/categories", h.requireRole(models.RoleAdmin, h.adminCategories))
+	mux.HandleFunc("/admin/categories/edit", h.requireRole(models.RoleAdmin, h.adminCategoryEdit))
+	mux.HandleFunc("/admin/categories/archive", h.requireRole(models.RoleAdmin, h.adminCategoryArchive))
 	mux.HandleFunc("/activate", h.activateAccount)
+	mux.HandleFunc("/activate/resend", h.activationResend)
+
+	mux.Handle(apiPrefix+"/", h.api())
 
-	return mux
+	return h.limitBody(h.authenticate(h.parseForm(csrf.Protect(h.app.Flash.Load(mux), http.HandlerFunc(h.csrfFailure)))))
 }
 
 type neuteredFileSystem struct {
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"forum/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const searchPageSize = 10
+
+func (h *handler) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, ok := parseSearchQuery(r)
+	if !ok {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := h.app.NewTemplateData(r)
+	categories, err := h.service.GetAllCategory()
+	if err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+	data.Categories = categories
+	data.Search = &q
+	data.CurrentPage = q.Page
+
+	if strings.TrimSpace(q.Text) != "" {
+		results, total, err := h.service.SearchPosts(q)
+		if err != nil {
+			h.app.ServerError(w, err)
+			return
+		}
+		data.NumberOfPage = (total + q.PageSize - 1) / q.PageSize
+		if q.Page > 1 && q.Page > data.NumberOfPage {
+			h.app.NotFound(w)
+			return
+		}
+		data.SearchResults = results
+	}
+	h.app.Render(w, http.StatusOK, "search.html", data)
+}
+
+// parseSearchQuery reads the search text and filters from the query string.
+// Empty filters are ignored; malformed ones make it report false.
+func parseSearchQuery(r *http.Request) (models.SearchQuery, bool) {
+	query := r.URL.Query()
+	q := models.SearchQuery{
+		Text:     strings.TrimSpace(query.Get("q")),
+		Author:   strings.TrimSpace(query.Get("author")),
+		Page:     defaultPage,
+		PageSize: searchPageSize,
+	}
+
+	var err error
+	if v := query.Get("category"); v != "" {
+		if q.CategoryID, err = strconv.Atoi(v); err != nil || q.CategoryID < 0 {
+			return q, false
+		}
+	}
+	if v := query.Get("page"); v != "" {
+		if q.Page, err = strconv.Atoi(v); err != nil || q.Page < 1 {
+			return q, false
+		}
+	}
+	if v := query.Get("from"); v != "" {
+		if q.From, err = time.Parse(models.DateLayout, v); err != nil {
+			return q, false
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if q.To, err = time.Parse(models.DateLayout, v); err != nil {
+			return q, false
+		}
+	}
+	return q, true
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"forum/app"
+	"forum/internal/config"
+	"forum/internal/repo"
+	"forum/internal/service"
+	"forum/models"
+	"forum/pkg/cookie"
+	"forum/pkg/csrf"
+	"forum/pkg/mailer"
+	"forum/pkg/media"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Categories seeded by the initial migration.
+const (
+	categoryTechnology = 1
+	categorySports     = 3
+)
+
+const (
+	aliceEmail   = "alice@example.com"
+	adminEmail   = "admin@example.com"
+	testPassword = "pa$$word"
+)
+
+// testPosts is how many posts newTestApplication seeds: enough for three
+// pages of the home page.
+const testPosts = 2*pageSize + 2
+
+// testApplication is the forum over an in-memory database holding two
+// activated users, alice and an admin, whose password is testPassword, and
+// testPosts posts by alice.
+type testApplication struct {
+	handler *handler
+	repo    repo.RepoI
+	mailer  *mailer.Memory
+
+	alice, admin int
+	// posts are the IDs of the seeded posts, oldest first. Even ones are in
+	// the technology category, odd ones in sports.
+	posts []int
+}
+
+func newTestApplication(t *testing.T) *testApplication {
+	t.Helper()
+
+	r, err := repo.New(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, err := app.NewTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := media.NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errLog := log.New(testLogWriter{t}, "ERROR\t", log.Lshortfile)
+	a := app.New(log.New(io.Discard, "", 0), errLog, tc, cookie.NewFlashStore(nil))
+	m := mailer.NewMemory()
+	ta := &testApplication{
+		handler: New(service.New(r, &config.Config{}, m, store), a),
+		repo:    r,
+		mailer:  m,
+	}
+
+	ta.alice = ta.newUser(t, "alice", aliceEmail, models.RoleUser)
+	ta.admin = ta.newUser(t, "admin", adminEmail, models.RoleAdmin)
+	for i := 0; i < testPosts; i++ {
+		category := categoryTechnology
+		if i%2 == 1 {
+			category = categorySports
+		}
+		content := fmt.Sprintf("Content of post %d", i+1)
+		id, err := r.CreatePost(ta.alice, fmt.Sprintf("Post %d", i+1), content, "<p>"+content+"</p>", "", []int{category})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ta.posts = append(ta.posts, id)
+	}
+	return ta
+}
+
+func (ta *testApplication) newUser(t *testing.T, name, email string, role models.Role) int {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.repo.CreateUser(&models.User{Name: name, Email: email, HashedPassword: hash}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := ta.repo.GetUserByEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.repo.ActivateUser(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.repo.UpdateUserRole(u.ID, role); err != nil {
+		t.Fatal(err)
+	}
+	return u.ID
+}
+
+// testLogWriter sends the server's error log to the test log.
+type testLogWriter struct {
+	t *testing.T
+}
+
+func (w testLogWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// testServer serves a testApplication to a client that keeps its cookies,
+// like a browser, but does not follow redirects.
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, ta *testApplication) *testServer {
+	t.Helper()
+	ts := httptest.NewServer(ta.handler.Routes())
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &testServer{ts}
+}
+
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	t.Helper()
+	resp, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, resp)
+}
+
+// postForm submits form to urlPath along with the client's CSRF token,
+// unless form carries a token of its own.
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	t.Helper()
+	if !form.Has("csrf_token") {
+		form.Set("csrf_token", ts.csrfToken(t))
+	}
+	resp, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, resp)
+}
+
+// sendJSON sends body, unless it is nil, as JSON to urlPath along with the
+// client's CSRF token in the X-CSRF-Token header.
+func (ts *testServer) sendJSON(t *testing.T, method, urlPath string, body any) (int, http.Header, string) {
+	t.Helper()
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, ts.URL+urlPath, reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(csrf.HeaderName, ts.csrfToken(t))
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, resp)
+}
+
+// csrfToken returns the token in the client's csrf_token cookie, visiting
+// the home page first if it has none yet.
+func (ts *testServer) csrfToken(t *testing.T) string {
+	t.Helper()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		for _, c := range ts.Client().Jar.Cookies(u) {
+			if c.Name == "csrf_token" {
+				return c.Value
+			}
+		}
+		ts.get(t, "/")
+	}
+	t.Fatal("no csrf_token cookie issued")
+	return ""
+}
+
+// login signs the client in, so that later requests carry its session.
+func (ts *testServer) login(t *testing.T, email, password string) {
+	t.Helper()
+	form := url.Values{}
+	form.Set("email", email)
+	form.Set("password", password)
+	code, _, body := ts.postForm(t, "/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login as %s: got %d; want %d\n%s", email, code, http.StatusSeeOther, body)
+	}
+}
+
+func readResponse(t *testing.T, resp *http.Response) (int, http.Header, string) {
+	t.Helper()
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header, string(bytes.TrimSpace(body))
+}
@@ -6,15 +6,21 @@ import (
 	"forum/pkg/cookie"
 	"forum/pkg/validator"
 	"net/http"
+	"strconv"
 )
 
+const activationSentMessage = "If an account with that email is waiting for activation, we have sent it a new link. " +
+	"A new link can be requested every few minutes."
+
 func (h *handler) login(w http.ResponseWriter, r *http.Request) {
 	methodResolver(w, r, h.loginGet, h.loginPost)
 }
 
 func (h *handler) loginGet(w http.ResponseWriter, r *http.Request) {
 	data := h.app.NewTemplateData(r)
-	data.Form = models.UserLoginForm{}
+	data.Form = models.UserLoginForm{
+		Next: r.URL.Query().Get("next"),
+	}
 	h.app.Render(w, http.StatusOK, "login.html", data)
 }
 
@@ -22,6 +28,7 @@ func (h *handler) loginPost(w http.ResponseWriter, r *http.Request) {
 	form := models.UserLoginForm{
 		Email:    r.FormValue("email"),
 		Password: r.FormValue("password"),
+		Next:     r.FormValue("next"),
 	}
 
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
@@ -34,7 +41,7 @@ func (h *handler) loginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := h.service.Authenticate(form.Email, form.Password)
+	session, err := h.service.Authenticate(form.Email, form.Password, r.UserAgent())
 
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) || errors.Is(err, models.ErrInvalidCredentials) {
@@ -53,7 +60,8 @@ func (h *handler) loginPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cookie.SetSessionCookie(w, session.Token, session.ExpTime)
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	h.flash(r, cookie.FlashSuccess, "You are now logged in.")
+	http.Redirect(w, r, safeRedirectPath(form.Next), http.StatusSeeOther)
 }
 
 func (h *handler) signup(w http.ResponseWriter, r *http.Request) {
@@ -99,6 +107,7 @@ func (h *handler) signupPost(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	h.flash(r, cookie.FlashSuccess, "Your signup was successful. Check your email for the link that activates your account.")
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 
 }
@@ -108,19 +117,23 @@ func (h *handler) logoutPost(w http.ResponseWriter, r *http.Request) {
 	if c != nil {
 		h.service.DeleteSession(c.Value)
 		cookie.ExpireSessionCookie(w)
+		h.flash(r, cookie.FlashInfo, "You have been logged out.")
 	}
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 func (h *handler) PostByUser(w http.ResponseWriter, r *http.Request) {
-	c := cookie.GetSessionCookie(r)
-	posts, err := h.service.GetAllPostByUser(c.Value)
+	posts, err := h.service.GetAllPostByUser(currentUser(r).ID)
 
 	if err != nil {
 		h.app.ServerError(w, err)
 		return
 	}
+	if err = h.attachReactions(r, *posts); err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
 
 	data := h.app.NewTemplateData(r)
 
@@ -130,18 +143,84 @@ func (h *handler) PostByUser(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (h *handler) likedPosts(w http.ResponseWriter, r *http.Request) {
+	posts, err := h.service.GetLikedPostsByUser(currentUser(r).ID)
+	if err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	data := h.app.NewTemplateData(r)
+	data.Posts = posts
+	h.app.Render(w, http.StatusOK, "liked_posts.html", data)
+}
+
 func (h *handler) userView(w http.ResponseWriter, r *http.Request) {
 	data := h.app.NewTemplateData(r)
-	c := cookie.GetSessionCookie(r)
+	data.User = currentUser(r)
+
+	h.app.Render(w, http.StatusOK, "user.html", data)
+}
+
+func (h *handler) accountSessions(w http.ResponseWriter, r *http.Request) {
+	var token string
+	if c := cookie.GetSessionCookie(r); c != nil {
+		token = c.Value
+	}
 
-	user, err := h.service.GetUserByToken(c.Value)
+	sessions, err := h.service.GetSessions(currentUser(r).ID, token)
 	if err != nil {
 		h.app.ServerError(w, err)
 		return
 	}
-	data.User = user
 
-	h.app.Render(w, http.StatusOK, "user.html", data)
+	data := h.app.NewTemplateData(r)
+	data.Sessions = sessions
+	h.app.Render(w, http.StatusOK, "sessions.html", data)
+}
+
+func (h *handler) revokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil || sessionID < 1 {
+		h.app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.RevokeSession(currentUser(r).ID, sessionID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			h.app.NotFound(w)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
+	}
+
+	h.flash(r, cookie.FlashSuccess, "The session has been revoked.")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+func (h *handler) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.app.ClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.service.RevokeAllSessions(currentUser(r).ID); err != nil {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	cookie.ExpireSessionCookie(w)
+	h.flash(r, cookie.FlashInfo, "You have been signed out on every device.")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
 func (h *handler) UpdateUserPassword(w http.ResponseWriter, r *http.Request) {
@@ -173,23 +252,77 @@ func (h *handler) UpdateUserPasswordPost(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	c := cookie.GetSessionCookie(r)
-
-	if err := h.service.UpdateUserPassword(c.Value, form.NewPassword); err != nil {
-		h.app.ServerError(w, err)
+	err := h.service.UpdateUserPassword(currentUser(r).ID, form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+			data := h.app.NewTemplateData(r)
+			data.Form = form
+			h.app.Render(w, http.StatusUnprocessableEntity, "password.html", data)
+		} else {
+			h.app.ServerError(w, err)
+		}
+		return
 	}
 
+	h.flash(r, cookie.FlashSuccess, "Your password has been updated.")
 	http.Redirect(w, r, "/account", http.StatusSeeOther)
 }
 
 func (h *handler) activateAccount(w http.ResponseWriter, r *http.Request) {
-	token := r.URL.Query().Get("token")
-
-	err := h.service.ActivateUser(token)
+	err := h.service.ActivateUser(r.URL.Query().Get("token"))
 	if err != nil {
-		http.Error(w, "Unable to activate account", http.StatusInternalServerError)
+		form := models.ActivationResendForm{}
+		switch {
+		case errors.Is(err, models.ErrInvalidToken):
+			form.AddFieldError("token", "This activation link is invalid or has already been used.")
+			h.renderActivation(w, r, http.StatusBadRequest, form)
+		case errors.Is(err, models.ErrTokenExpired):
+			form.AddFieldError("token", "This activation link has expired. Request a new one below.")
+			h.renderActivation(w, r, http.StatusGone, form)
+		default:
+			h.app.ServerError(w, err)
+		}
 		return
 	}
 
-	http.Redirect(w, r, "/login?activated=true", http.StatusSeeOther)
+	h.flash(r, cookie.FlashSuccess, "Your account has been activated. You can now log in.")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (h *handler) activationResend(w http.ResponseWriter, r *http.Request) {
+	methodResolver(w, r, h.activationResendGet, h.activationResendPost)
+}
+
+func (h *handler) activationResendGet(w http.ResponseWriter, r *http.Request) {
+	h.renderActivation(w, r, http.StatusOK, models.ActivationResendForm{})
+}
+
+func (h *handler) activationResendPost(w http.ResponseWriter, r *http.Request) {
+	form := models.ActivationResendForm{
+		Email: r.FormValue("email"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	if !form.Valid() {
+		h.renderActivation(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	// A rate-limited request gets the same answer as a successful one, so
+	// the response does not tell whether the address is registered.
+	err := h.service.ResendActivation(form.Email)
+	if err != nil && !errors.Is(err, models.ErrRateLimited) {
+		h.app.ServerError(w, err)
+		return
+	}
+
+	h.flash(r, cookie.FlashInfo, activationSentMessage)
+	http.Redirect(w, r, "/activate/resend", http.StatusSeeOther)
+}
+
+func (h *handler) renderActivation(w http.ResponseWriter, r *http.Request, status int, form models.ActivationResendForm) {
+	data := h.app.NewTemplateData(r)
+	data.Form = form
+	h.app.Render(w, status, "activate.html", data)
 }
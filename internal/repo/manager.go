@@ -1,8 +1,13 @@
 package repo
 
 import (
+	"fmt"
+	"forum/internal/repo/postgres"
 	"forum/internal/repo/sqlite"
 	"forum/models"
+	"forum/pkg/migrate"
+	"strings"
+	"time"
 )
 
 type UserRepo interface {
@@ -12,61 +17,174 @@ type UserRepo interface {
 	// UpdateUserByID(string) (*models.User, error)
 	Authenticate(email, password string) (int, error)
 	UpdateUserPassword(id int, password string) error
+	CheckUserPassword(id int, password string) error
 	UpdateUserEmail(id int, email string) error
 	UpdateUserName(id int, name string) error
+	SetPendingEmail(id int, email, tokenHash string, expires time.Time) error
+	ConfirmPendingEmail(id int) error
 	GetAllUsers() ([]*models.User, error)
 	DeleteUser(int) error
+	GetUserFootprint(int) (*models.UserFootprint, error)
+	UpdateUserRole(id int, role models.Role) error
 }
 
 type SessionRepo interface {
-	GetUserIDByToken(string) (int, error)
+	GetSessionByToken(string) (*models.Session, error)
+	GetSessionsByUserID(int) ([]models.Session, error)
 	CreateSession(*models.Session) error
+	RenewSession(token string, lastSeen, expTime time.Time) error
 	DeleteSessionByUserID(int) error
 	DeleteSessionByToken(string) error
+	DeleteSessionByID(userID, sessionID int) error
+	DeleteExpiredSessions(now time.Time) (int64, error)
 }
 
 type PostRepo interface {
-	CreatePost(userID int, title, content, imageName string) (int, error)
+	CreatePost(userID int, title, content, contentHTML, imageName string, categories []int) (int, error)
 	GetPostByID(int) (*models.Post, error)
-	GetCategoriesByPostID(int) (map[int]string, error)
-	// GetAllPost() (*models.Post, error)
-	// UpdatePost(string, *models.Post) error
-	//AddLikeAndDislike(bool, string, string) error
-	// DeleteLikeAndDislike(int, int) error
+	GetCategoriesByPostID(int) ([]models.Category, error)
+	UpdatePost(postID, editorID int, title, content, contentHTML string, categories []int, now time.Time) error
+	GetPostRevisions(postID int) ([]models.PostRevision, error)
+	DeletePost(postID int, now time.Time) error
+	RestorePost(postID int) error
+	GetDeletedPosts() ([]models.Post, error)
+	PurgePost(postID int) error
+	PurgeDeletedPosts(before time.Time) (int64, error)
+	ReactToPost(userID, postID int, isLike bool) error
+	GetPostReactionsByUser(userID int, postIDs []int) (map[int]models.Reaction, error)
+	GetLikedPostsByUserID(int) (*[]models.Post, error)
 	GetAllPostByUserID(int) (*[]models.Post, error)
 	GetAllPostByCategories(categories []int) (*[]models.Post, error)
 	GetPageNumber(pageSize int) (int, error)
 	GetAllPostPaginated(page int, pageSize int) (*[]models.Post, error)
+	SearchPosts(models.SearchQuery) ([]models.SearchResult, int, error)
 }
 
 type CategoryRepo interface {
 	CreateParsePosts() error
 	AddCategoryToPost(int, []int) error
-	GetALLCategory() ([]string, error)
-	// CreateCategory(string) error
+	GetALLCategory() ([]models.Category, error)
+	GetCategoryByID(int) (*models.Category, error)
+	CreateCategory(*models.Category) (int, error)
+	UpdateCategory(*models.Category) error
+	SetCategoryArchived(id int, archived bool) error
 }
 
-// type CommentRepo interface {
-// 	CreateComment(*models.Comment) error
-// 	GetAllCommentByPostID(string) (*[]models.Post, error)
-// 	GetAllCommentByUserID(string) (*[]models.Post, error)
-// 	AddLikeAndDislike(bool, string, string) error
-// }
+type CommentRepo interface {
+	CreateComment(*models.Comment) (int, error)
+	GetCommentByID(int) (*models.Comment, error)
+	GetCommentsByPostID(int) ([]models.Comment, error)
+	GetCommentThread(commentID int) ([]models.Comment, error)
+	ReactToComment(userID, commentID int, isLike bool) error
+	GetCommentReactionsByUser(userID int, commentIDs []int) (map[int]models.Reaction, error)
+}
+
+type OutboxRepo interface {
+	EnqueueMail(*models.OutboxMessage) error
+	GetDueMail(now time.Time, limit int) ([]models.OutboxMessage, error)
+	MarkMailSent(id int, sent time.Time) error
+	MarkMailFailed(id int, status models.OutboxStatus, nextAttempt time.Time, lastError string) error
+}
+
+type PasswordResetRepo interface {
+	CreatePasswordReset(*models.PasswordReset) error
+	GetPasswordReset(tokenHash string) (*models.PasswordReset, error)
+	ResetPassword(tokenHash string, now time.Time, password string) (int, error)
+}
 
 type RepoI interface {
 	UserRepo
 	SessionRepo
 	PostRepo
 	CategoryRepo
-	GetUserByActivationToken(activationToken string) (*models.User, error)
+	CommentRepo
+	OutboxRepo
+	PasswordResetRepo
+	GetUserByActivationToken(tokenHash string) (*models.User, error)
 	ActivateUser(userID int) error
-	// CommentRepo
+	RenewActivationToken(id int, tokenHash string, expires, sent time.Time) error
 }
 
-func New(storagePath string) (RepoI, error) {
-	sqliteDB, err := sqlite.NewDB(storagePath)
+// New opens the storage named by dsn: a postgres:// or postgresql:// URL
+// selects PostgreSQL; a path, with or without a sqlite:// prefix, or a
+// file:// URI selects SQLite. The path :memory: opens a fresh in-memory
+// SQLite database, see sqlite.NewMemory.
+func New(dsn string) (RepoI, error) {
+	backend, source, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if backend == backendPostgres {
+		postgresDB, err := postgres.NewDB(source)
+		if err != nil {
+			return nil, err
+		}
+		return postgresDB, nil
+	}
+	if source == sqliteMemory {
+		memoryDB, err := sqlite.NewMemory()
+		if err != nil {
+			return nil, err
+		}
+		return memoryDB, nil
+	}
+	sqliteDB, err := sqlite.NewDB(source)
 	if err != nil {
 		return nil, err
 	}
 	return sqliteDB, nil
 }
+
+const (
+	backendSQLite   = "sqlite"
+	backendPostgres = "postgres"
+
+	sqliteMemory = ":memory:"
+)
+
+// parseDSN returns the backend dsn selects and what to pass to its driver.
+func parseDSN(dsn string) (backend, source string, err error) {
+	scheme, rest, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return backendSQLite, dsn, nil
+	}
+	switch scheme {
+	case "postgres", "postgresql":
+		return backendPostgres, dsn, nil
+	case "sqlite", "sqlite3":
+		return backendSQLite, rest, nil
+	case "file":
+		// An SQLite URI filename, which the driver understands as is.
+		return backendSQLite, dsn, nil
+	}
+	return "", "", fmt.Errorf("repo: unsupported storage %q", scheme+"://")
+}
+
+// Migrator brings a database schema to a given version, see migrate.Migrator.
+type Migrator interface {
+	Status() ([]migrate.Status, error)
+	Up() (int, error)
+	Down() (int, error)
+	To(version int) (int, error)
+	Close() error
+}
+
+// NewMigrator opens the storage named by dsn, see New, for migrating.
+func NewMigrator(dsn string) (Migrator, error) {
+	backend, source, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if backend == backendPostgres {
+		m, err := postgres.NewMigrator(source)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	m, err := sqlite.NewMigrator(source)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
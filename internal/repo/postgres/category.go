@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+)
+
+func (p *Postgres) AddCategoryToPost(postID int, categories []int) error {
+	const op = "postgres.AddCategoryToPost"
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = insertPostCategories(tx, postID, categories); err != nil {
+		if errors.Is(err, models.ErrInvalidCategory) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+	return nil
+}
+
+// insertPostCategories attaches the post to the given categories within tx.
+// An unknown or archived id is reported as models.ErrInvalidCategory.
+func insertPostCategories(tx *sql.Tx, postID int, categories []int) error {
+	stmt, err := tx.Prepare(`INSERT INTO post_category (post_id, category_id)
+		SELECT $1::integer, id FROM category WHERE id = $2 AND NOT archived`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, categoryID := range categories {
+		result, err := stmt.Exec(postID, categoryID)
+		if err != nil {
+			return fmt.Errorf("exec statement: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("exec statement: %w", err)
+		}
+		if n != 1 {
+			return models.ErrInvalidCategory
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) GetALLCategory() ([]models.Category, error) {
+	op := "postgres.GetAllCategory"
+	stmt := `SELECT id, name, slug, description, position, archived FROM category ORDER BY position ASC, id ASC`
+
+	categories, err := p.queryCategories(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return categories, nil
+}
+
+func (p *Postgres) GetCategoryByID(id int) (*models.Category, error) {
+	op := "postgres.GetCategoryByID"
+	stmt := `SELECT id, name, slug, description, position, archived FROM category WHERE id = $1`
+
+	var c models.Category
+	err := p.db.QueryRow(stmt, id).Scan(&c.ID, &c.Name, &c.Slug, &c.Description, &c.Position, &c.Archived)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &c, nil
+}
+
+func (p *Postgres) CreateCategory(c *models.Category) (int, error) {
+	op := "postgres.CreateCategory"
+
+	if c.Position == 0 {
+		err := p.db.QueryRow(`SELECT COALESCE(MAX(position), 0) + 1 FROM category`).Scan(&c.Position)
+		if err != nil {
+			return -1, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	var id int
+	stmt := `INSERT INTO category (name, slug, description, position) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := p.db.QueryRow(stmt, c.Name, c.Slug, c.Description, c.Position).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err, "idx_category_slug") {
+			return -1, models.ErrDuplicateCategory
+		}
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+func (p *Postgres) UpdateCategory(c *models.Category) error {
+	op := "postgres.UpdateCategory"
+	stmt := `UPDATE category SET name = $1, slug = $2, description = $3, position = $4 WHERE id = $5`
+
+	result, err := p.db.Exec(stmt, c.Name, c.Slug, c.Description, c.Position, c.ID)
+	if err != nil {
+		if isUniqueViolation(err, "idx_category_slug") {
+			return models.ErrDuplicateCategory
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) SetCategoryArchived(id int, archived bool) error {
+	op := "postgres.SetCategoryArchived"
+	result, err := p.db.Exec(`UPDATE category SET archived = $1 WHERE id = $2`, archived, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) GetCategoriesByPostID(postID int) ([]models.Category, error) {
+	op := "postgres.GetCategoriesByPostID"
+	stmt := `SELECT c.id, c.name, c.slug, c.description, c.position, c.archived
+	FROM post_category pc
+	INNER JOIN category c ON pc.category_id = c.id
+	WHERE pc.post_id = $1
+	ORDER BY c.position ASC, c.id ASC`
+
+	categories, err := p.queryCategories(stmt, postID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return categories, nil
+}
+
+func (p *Postgres) queryCategories(stmt string, args ...any) ([]models.Category, error) {
+	rows, err := p.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.Description, &c.Position, &c.Archived); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+)
+
+func (p *Postgres) CreateComment(c *models.Comment) (int, error) {
+	op := "postgres.CreateComment"
+	const query = `INSERT INTO comments (post_id, user_id, content, content_html, parent_id) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	var parentID sql.NullInt64
+	if c.ParentID != 0 {
+		parentID = sql.NullInt64{Int64: int64(c.ParentID), Valid: true}
+	}
+
+	var commentID int
+	if err := p.db.QueryRow(query, c.PostID, c.UserID, c.Content, c.ContentHTML, parentID).Scan(&commentID); err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+	return commentID, nil
+}
+
+func (p *Postgres) GetCommentByID(commentID int) (*models.Comment, error) {
+	op := "postgres.GetCommentByID"
+	stmt := `SELECT c.id, c.post_id, c.parent_id, c.user_id, u.name, c.content, COALESCE(c.content_html, ''), c.created, c."like", c.dislike
+	FROM comments c
+	JOIN users u ON c.user_id = u.id
+	WHERE c.id = $1`
+
+	var c models.Comment
+	var parentID sql.NullInt64
+	err := p.db.QueryRow(stmt, commentID).Scan(&c.CommentID, &c.PostID, &parentID, &c.UserID, &c.UserName, &c.Content, &c.ContentHTML, &c.Created, &c.Like, &c.Dislike)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	c.ParentID = int(parentID.Int64)
+	return &c, nil
+}
+
+// commentTreeQuery walks a comment tree depth-first. The anchor selects the
+// roots; the sort path zero-pads ids so that siblings keep creation order.
+const commentTreeQuery = `WITH RECURSIVE thread(id, depth, path) AS (
+		SELECT id, 0, lpad(id::text, 10, '0') FROM comments WHERE %s
+		UNION ALL
+		SELECT c.id, t.depth + 1, t.path || '/' || lpad(c.id::text, 10, '0')
+		FROM comments c
+		JOIN thread t ON c.parent_id = t.id
+	)
+	SELECT c.id, c.post_id, c.parent_id, c.user_id, u.name, c.content, COALESCE(c.content_html, ''), c.created, c."like", c.dislike, t.depth
+	FROM thread t
+	JOIN comments c ON c.id = t.id
+	JOIN users u ON c.user_id = u.id
+	ORDER BY t.path`
+
+func (p *Postgres) GetCommentsByPostID(postID int) ([]models.Comment, error) {
+	op := "postgres.GetCommentsByPostID"
+	stmt := fmt.Sprintf(commentTreeQuery, "post_id = $1 AND parent_id IS NULL")
+
+	comments, err := p.queryCommentTree(stmt, postID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return comments, nil
+}
+
+func (p *Postgres) GetCommentThread(commentID int) ([]models.Comment, error) {
+	op := "postgres.GetCommentThread"
+	stmt := fmt.Sprintf(commentTreeQuery, "id = $1")
+
+	comments, err := p.queryCommentTree(stmt, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(comments) == 0 {
+		return nil, models.ErrNoRecord
+	}
+	return comments, nil
+}
+
+func (p *Postgres) queryCommentTree(stmt string, args ...any) ([]models.Comment, error) {
+	rows, err := p.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []models.Comment
+	for rows.Next() {
+		var c models.Comment
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.CommentID, &c.PostID, &parentID, &c.UserID, &c.UserName, &c.Content, &c.ContentHTML, &c.Created, &c.Like, &c.Dislike, &c.Depth); err != nil {
+			return nil, err
+		}
+		c.ParentID = int(parentID.Int64)
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (p *Postgres) ReactToComment(userID, commentID int, isLike bool) error {
+	if err := p.toggleReaction(commentReactions, userID, commentID, isLike); err != nil {
+		return fmt.Errorf("postgres.ReactToComment: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) GetCommentReactionsByUser(userID int, commentIDs []int) (map[int]models.Reaction, error) {
+	reactions, err := p.getReactionsByUser(commentReactions, userID, commentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.GetCommentReactionsByUser: %w", err)
+	}
+	return reactions, nil
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"forum/pkg/migrate"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+func migrations() fs.FS {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
+
+// Migrator applies the migrations in the migrations directory to a database.
+// Unlike SQLite, PostgreSQL has no databases that predate migrations, so
+// there is no legacy schema to upgrade first.
+type Migrator struct {
+	*migrate.Migrator
+	db *sql.DB
+}
+
+func NewMigrator(dsn string) (*Migrator, error) {
+	const op = "postgres.NewMigrator"
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	m, err := migrate.New(db, migrations(), migrate.DollarPlaceholders)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &Migrator{Migrator: m, db: db}, nil
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
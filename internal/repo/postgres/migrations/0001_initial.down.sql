@@ -0,0 +1,11 @@
+DROP TABLE password_resets;
+DROP TABLE outbox;
+DROP TABLE comment_user_like;
+DROP TABLE comments;
+DROP TABLE post_category;
+DROP TABLE category;
+DROP TABLE post_user_like;
+DROP TABLE post_revisions;
+DROP TABLE posts;
+DROP TABLE sessions;
+DROP TABLE users;
@@ -0,0 +1,149 @@
+-- The schema of the SQLite backend as of its foreign_keys migration, with
+-- the search indexes that SQLite keeps in FTS5 tables. "like" is quoted
+-- because it is a keyword in PostgreSQL.
+
+CREATE TABLE users (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL CONSTRAINT users_email_key UNIQUE,
+	hashed_password TEXT NOT NULL,
+	created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	status INTEGER DEFAULT 0,
+	role TEXT NOT NULL DEFAULT 'user',
+	activation_token TEXT,
+	is_activated BOOLEAN,
+	pending_email TEXT,
+	activation_expires TIMESTAMPTZ,
+	activation_sent TIMESTAMPTZ
+);
+
+-- The deleted user placeholder takes over the posts and comments of deleted
+-- accounts.
+INSERT INTO users (id, name, email, hashed_password, role, is_activated)
+VALUES (0, 'deleted user', '', '', 'user', TRUE);
+
+CREATE TABLE sessions (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token TEXT NOT NULL,
+	exp_time TIMESTAMPTZ NOT NULL,
+	created TIMESTAMPTZ,
+	last_seen TIMESTAMPTZ,
+	user_agent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE posts (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	user_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE SET DEFAULT,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	content_html TEXT,
+	created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	"like" INTEGER DEFAULT 0,
+	dislike INTEGER DEFAULT 0,
+	image_name TEXT,
+	updated TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ
+);
+
+CREATE TABLE post_revisions (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	editor_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE SET DEFAULT,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	categories TEXT NOT NULL DEFAULT '',
+	created TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE post_user_like (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	is_like BOOLEAN,
+	PRIMARY KEY (user_id, post_id)
+);
+
+CREATE TABLE category (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name TEXT NOT NULL,
+	slug TEXT,
+	description TEXT NOT NULL DEFAULT '',
+	position INTEGER NOT NULL DEFAULT 0,
+	archived BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE post_category (
+	category_id INTEGER NOT NULL REFERENCES category(id) ON DELETE CASCADE,
+	post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	PRIMARY KEY (category_id, post_id)
+);
+
+CREATE TABLE comments (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE SET DEFAULT,
+	created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	content TEXT NOT NULL,
+	content_html TEXT,
+	"like" INTEGER DEFAULT 0,
+	dislike INTEGER DEFAULT 0,
+	parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE TABLE comment_user_like (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+	is_like BOOLEAN,
+	PRIMARY KEY (user_id, comment_id)
+);
+
+CREATE TABLE outbox (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	recipient TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	body TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt TIMESTAMPTZ NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	created TIMESTAMPTZ NOT NULL,
+	sent TIMESTAMPTZ
+);
+
+CREATE TABLE password_resets (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	created TIMESTAMPTZ NOT NULL,
+	expires TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_category_slug ON category(slug);
+CREATE UNIQUE INDEX idx_sessions_token ON sessions(token);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_posts_user_id ON posts(user_id);
+CREATE INDEX idx_posts_deleted_at ON posts(deleted_at);
+CREATE INDEX idx_post_revisions_post_id ON post_revisions(post_id);
+CREATE INDEX idx_post_revisions_editor_id ON post_revisions(editor_id);
+CREATE INDEX idx_post_user_like_post_id ON post_user_like(post_id);
+CREATE INDEX idx_post_category_post_id ON post_category(post_id);
+CREATE INDEX idx_comments_post_id ON comments(post_id);
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+CREATE INDEX idx_comments_user_id ON comments(user_id);
+CREATE INDEX idx_comment_user_like_comment_id ON comment_user_like(comment_id);
+CREATE INDEX idx_outbox_status ON outbox(status);
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
+
+-- These expressions must match postDocument and commentDocument in search.go
+-- for searches to use the indexes.
+CREATE INDEX idx_posts_search ON posts USING GIN (
+	(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B'))
+);
+CREATE INDEX idx_comments_search ON comments USING GIN (to_tsvector('simple', content));
+
+INSERT INTO category (name, slug, position) VALUES
+	('Technology', 'technology', 1),
+	('Entertainment', 'entertainment', 2),
+	('Sports', 'sports', 3),
+	('Education', 'education', 4);
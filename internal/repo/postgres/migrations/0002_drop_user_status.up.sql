@@ -0,0 +1,3 @@
+-- users.status was never read; roles are kept in users.role.
+
+ALTER TABLE users DROP COLUMN status;
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+func (p *Postgres) EnqueueMail(m *models.OutboxMessage) error {
+	op := "postgres.EnqueueMail"
+	stmt := `INSERT INTO outbox (recipient, subject, body, status, next_attempt, created) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	err := p.db.QueryRow(stmt, m.Recipient, m.Subject, m.Body, models.OutboxPending, m.NextAttempt, m.Created).Scan(&m.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	m.Status = models.OutboxPending
+	return nil
+}
+
+func (p *Postgres) GetDueMail(now time.Time, limit int) ([]models.OutboxMessage, error) {
+	op := "postgres.GetDueMail"
+	stmt := `SELECT id, recipient, subject, body, status, attempts, next_attempt, last_error, created
+	FROM outbox
+	WHERE status = $1 AND next_attempt <= $2
+	ORDER BY id
+	LIMIT $3`
+
+	rows, err := p.db.Query(stmt, models.OutboxPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var m models.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Recipient, &m.Subject, &m.Body, &m.Status, &m.Attempts, &m.NextAttempt, &m.LastError, &m.Created); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		messages = append(messages, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return messages, nil
+}
+
+func (p *Postgres) MarkMailSent(id int, sent time.Time) error {
+	op := "postgres.MarkMailSent"
+	stmt := `UPDATE outbox SET status = $1, attempts = attempts + 1, last_error = '', sent = $2 WHERE id = $3`
+
+	result, err := p.db.Exec(stmt, models.OutboxSent, sent, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) MarkMailFailed(id int, status models.OutboxStatus, nextAttempt time.Time, lastError string) error {
+	op := "postgres.MarkMailFailed"
+	stmt := `UPDATE outbox SET status = $1, attempts = attempts + 1, next_attempt = $2, last_error = $3 WHERE id = $4`
+
+	result, err := p.db.Exec(stmt, status, nextAttempt, lastError, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
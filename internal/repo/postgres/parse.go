@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"forum/internal/repo/sqlite"
+	"forum/models"
+	"math/rand"
+	"strings"
+)
+
+// CreateParsePosts seeds the forum with news articles, as the SQLite backend
+// does.
+func (p *Postgres) CreateParsePosts() error {
+	posts, err := sqlite.ParseNews()
+	if err != nil {
+		return err
+	}
+
+	all, err := p.GetALLCategory()
+	if err != nil {
+		return err
+	}
+	var categoryIDs []int
+	for _, c := range all {
+		if !c.Archived {
+			categoryIDs = append(categoryIDs, c.ID)
+		}
+	}
+
+	for _, post := range *posts {
+		userID := 1
+		if post.Author != "" {
+			userID, err = p.createParseUser(post.Author)
+			if err != nil {
+				if isUniqueViolation(err, "users_email_key") {
+					continue
+				}
+				return err
+			}
+		}
+
+		n := 2
+		if n > len(categoryIDs) {
+			n = len(categoryIDs)
+		}
+		picked := make([]int, n)
+		for i, j := range rand.Perm(len(categoryIDs))[:n] {
+			picked[i] = categoryIDs[j]
+		}
+		if _, err = p.CreatePost(userID, post.Title, post.Content, "", post.ImgURL, picked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) createParseUser(name string) (int, error) {
+	form := models.UserSignupForm{
+		Name:     name,
+		Email:    parseEmail(name),
+		Password: "nothingToSay",
+	}
+	u := form.FormToUser()
+
+	var id int
+	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES ($1, $2, $3, CURRENT_TIMESTAMP) RETURNING id`
+	err := p.db.QueryRow(stmt, u.Name, u.Email, string(u.HashedPassword)).Scan(&id)
+	return id, err
+}
+
+func parseEmail(name string) string {
+	if i := strings.IndexByte(name, ' '); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+func (p *Postgres) CreatePasswordReset(r *models.PasswordReset) error {
+	op := "postgres.CreatePasswordReset"
+	stmt := `INSERT INTO password_resets (user_id, token_hash, created, expires) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	if err := p.db.QueryRow(stmt, r.UserID, r.TokenHash, r.Created, r.Expires).Scan(&r.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (p *Postgres) GetPasswordReset(tokenHash string) (*models.PasswordReset, error) {
+	op := "postgres.GetPasswordReset"
+	stmt := `SELECT id, user_id, token_hash, created, expires, used_at IS NOT NULL
+	FROM password_resets WHERE token_hash = $1`
+
+	var r models.PasswordReset
+	err := p.db.QueryRow(stmt, tokenHash).Scan(&r.ID, &r.UserID, &r.TokenHash, &r.Created, &r.Expires, &r.Used)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &r, nil
+}
+
+// ResetPassword redeems a reset link as the SQLite backend does. The link is
+// locked so that it cannot be redeemed twice concurrently.
+func (p *Postgres) ResetPassword(tokenHash string, now time.Time, password string) (int, error) {
+	op := "postgres.ResetPassword"
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var r models.PasswordReset
+	err = tx.QueryRow(`SELECT user_id, expires, used_at IS NOT NULL FROM password_resets WHERE token_hash = $1 FOR UPDATE`, tokenHash).
+		Scan(&r.UserID, &r.Expires, &r.Used)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidToken
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !r.Usable(now) {
+		return 0, models.ErrInvalidToken
+	}
+
+	queries := []struct {
+		stmt string
+		args []any
+	}{
+		{`UPDATE users SET hashed_password = $1 WHERE id = $2`, []any{hashedPassword, r.UserID}},
+		{`UPDATE password_resets SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`, []any{now, r.UserID}},
+		{`DELETE FROM sessions WHERE user_id = $1`, []any{r.UserID}},
+	}
+	for _, q := range queries {
+		if _, err = tx.Exec(q.stmt, q.args...); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return r.UserID, nil
+}
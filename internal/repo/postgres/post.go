@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+
+	"github.com/lib/pq"
+)
+
+// CreatePost inserts a post together with its categories, so that a post
+// is never left without the categories it was published under.
+func (p *Postgres) CreatePost(userID int, title, content, contentHTML, imageName string, categories []int) (int, error) {
+	op := "postgres.CreatePost"
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	const query = `INSERT INTO posts (user_id, title, content, content_html, image_name) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	var postID int
+	if err = tx.QueryRow(query, userID, title, content, contentHTML, imageName).Scan(&postID); err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = insertPostCategories(tx, postID, categories); err != nil {
+		if errors.Is(err, models.ErrInvalidCategory) {
+			return -1, err
+		}
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return -1, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+	return postID, nil
+}
+
+func (p *Postgres) GetPostByID(postID int) (*models.Post, error) {
+	op := "postgres.GetPostByID"
+	stmt := `SELECT p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created, p.updated, p."like", p.dislike, COALESCE(p.image_name, ''), u.name
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.id = $1 AND p.deleted_at IS NULL`
+	post := models.Post{}
+	var updated sql.NullTime
+
+	err := p.db.QueryRow(stmt, postID).Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.ContentHTML, &post.Created, &updated, &post.Like, &post.Dislike, &post.ImageName, &post.UserName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	post.Updated = updated.Time
+	return &post, nil
+}
+
+func (p *Postgres) ReactToPost(userID, postID int, isLike bool) error {
+	if err := p.toggleReaction(postReactions, userID, postID, isLike); err != nil {
+		return fmt.Errorf("postgres.ReactToPost: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) GetPostReactionsByUser(userID int, postIDs []int) (map[int]models.Reaction, error) {
+	reactions, err := p.getReactionsByUser(postReactions, userID, postIDs)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.GetPostReactionsByUser: %w", err)
+	}
+	return reactions, nil
+}
+
+// postListColumns are the columns scanned by queryPosts.
+const postListColumns = `p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created, p."like", p.dislike, COALESCE(p.image_name, ''), u.name`
+
+// queryPosts runs a query selecting postListColumns.
+func (p *Postgres) queryPosts(stmt string, args ...any) ([]models.Post, error) {
+	rows, err := p.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		var post models.Post
+		if err := rows.Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.ContentHTML, &post.Created, &post.Like, &post.Dislike, &post.ImageName, &post.UserName); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+func (p *Postgres) GetLikedPostsByUserID(userID int) (*[]models.Post, error) {
+	op := "postgres.GetLikedPostsByUserID"
+	stmt := `SELECT ` + postListColumns + `
+	FROM post_user_like pul
+	JOIN posts p ON pul.post_id = p.id
+	JOIN users u ON p.user_id = u.id
+	WHERE pul.user_id = $1 AND pul.is_like AND p.deleted_at IS NULL
+	ORDER BY p.created DESC`
+
+	posts, err := p.queryPosts(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for i := range posts {
+		posts[i].Reaction = models.ReactionLike
+	}
+	return &posts, nil
+}
+
+func (p *Postgres) GetAllPostByUserID(userID int) (*[]models.Post, error) {
+	op := "postgres.GetAllPostByUserID"
+	stmt := `SELECT ` + postListColumns + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.user_id = $1 AND p.deleted_at IS NULL
+	ORDER BY p.id`
+
+	posts, err := p.queryPosts(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &posts, nil
+}
+
+func (p *Postgres) GetAllPostByCategories(categoryIDs []int) (*[]models.Post, error) {
+	op := "postgres.GetAllPostByCategories"
+	stmt := `SELECT ` + postListColumns + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE EXISTS (SELECT 1 FROM post_category pc WHERE pc.post_id = p.id AND pc.category_id = ANY($1))
+		AND p.deleted_at IS NULL
+	ORDER BY p.id`
+
+	posts, err := p.queryPosts(stmt, pq.Array(categoryIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &posts, nil
+}
+
+func (p *Postgres) GetAllPostPaginated(page int, pageSize int) (*[]models.Post, error) {
+	op := "postgres.GetAllPostPaginated"
+	offset := (page - 1) * pageSize
+	stmt := `SELECT ` + postListColumns + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.deleted_at IS NULL
+	ORDER BY p.id
+	LIMIT $1 OFFSET $2`
+
+	posts, err := p.queryPosts(stmt, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &posts, nil
+}
+
+func (p *Postgres) GetPageNumber(pageSize int) (int, error) {
+	op := "postgres.GetPageNumber"
+
+	var totalPosts int
+	err := p.db.QueryRow(`SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL`).Scan(&totalPosts)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return (totalPosts + pageSize - 1) / pageSize, nil
+}
@@ -0,0 +1,141 @@
+// Package postgres implements the forum repository on PostgreSQL. It
+// mirrors the sqlite package query for query; see there for the behaviour
+// of each method.
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+	"forum/pkg/migrate"
+
+	"github.com/lib/pq"
+)
+
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewDB connects to the database at dsn, a postgres:// URL. It refuses a
+// database whose schema does not match the migrations of this build; see
+// NewMigrator.
+func NewDB(dsn string) (*Postgres, error) {
+	const op = "postgres.NewDB"
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.New(db, migrations(), migrate.DollarPlaceholders)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = m.Check(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Postgres{db: db}, nil
+}
+
+func (p *Postgres) GetAllUsers() ([]*models.User, error) {
+	var users []*models.User
+	rows, err := p.db.Query("SELECT id, name, email, hashed_password, created, role FROM users WHERE id <> $1 ORDER BY id", models.DeletedUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.HashedPassword, &u.Created, &u.Role)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &u)
+	}
+
+	return users, rows.Err()
+}
+
+// DeleteUser deletes a user other than the last admin; foreign keys treat
+// their content as in SQLite.
+func (p *Postgres) DeleteUser(id int) error {
+	op := "postgres.DeleteUser"
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = checkLastAdmin(op, tx, id); err != nil {
+		return err
+	}
+
+	for _, t := range []reactionTarget{postReactions, commentReactions} {
+		query := fmt.Sprintf(`UPDATE %[1]s SET
+			"like" = GREATEST("like" - (SELECT COUNT(*) FROM %[2]s r WHERE r.%[3]s = %[1]s.id AND r.user_id = $1 AND r.is_like), 0),
+			dislike = GREATEST(dislike - (SELECT COUNT(*) FROM %[2]s r WHERE r.%[3]s = %[1]s.id AND r.user_id = $1 AND NOT r.is_like), 0)
+		WHERE id IN (SELECT %[3]s FROM %[2]s WHERE user_id = $1)`, t.counterTable, t.reactionTable, t.idColumn)
+		if _, err = tx.Exec(query, id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = checkAffected(op, result); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+	return nil
+}
+
+// GetUserFootprint counts the content and sessions DeleteUser would affect.
+func (p *Postgres) GetUserFootprint(id int) (*models.UserFootprint, error) {
+	op := "postgres.GetUserFootprint"
+	stmt := `SELECT
+		(SELECT COUNT(*) FROM posts WHERE user_id = $1),
+		(SELECT COUNT(*) FROM comments WHERE user_id = $1),
+		(SELECT COUNT(*) FROM post_user_like WHERE user_id = $1) + (SELECT COUNT(*) FROM comment_user_like WHERE user_id = $1),
+		(SELECT COUNT(*) FROM sessions WHERE user_id = $1)`
+
+	var f models.UserFootprint
+	if err := p.db.QueryRow(stmt, id).Scan(&f.Posts, &f.Comments, &f.Reactions, &f.Sessions); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &f, nil
+}
+
+// isUniqueViolation reports whether err is a unique_violation of the named
+// constraint or unique index.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// checkAffected turns an UPDATE or DELETE that matched no rows into
+// models.ErrNoRecord.
+func checkAffected(op string, result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
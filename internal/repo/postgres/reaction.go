@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"fmt"
+	"forum/models"
+
+	"github.com/lib/pq"
+)
+
+// reactionTarget names the table holding per-user reactions to one kind of
+// content and the table whose like/dislike counters mirror it.
+type reactionTarget struct {
+	reactionTable string
+	idColumn      string
+	counterTable  string
+}
+
+var (
+	postReactions    = reactionTarget{"post_user_like", "post_id", "posts"}
+	commentReactions = reactionTarget{"comment_user_like", "comment_id", "comments"}
+)
+
+// toggleReaction sets the user's reaction on a post or comment as the SQLite
+// backend does. An existing reaction is locked until the counters are
+// updated, so two concurrent toggles cannot both remove it.
+func (p *Postgres) toggleReaction(t reactionTarget, userID, targetID int, isLike bool) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The reaction is inserted first, so that two concurrent first reactions
+	// cannot both find none and then collide on the primary key.
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, %s, is_like) VALUES ($1, $2, $3) ON CONFLICT (user_id, %s) DO NOTHING`, t.reactionTable, t.idColumn, t.idColumn)
+	result, err := tx.Exec(query, userID, targetID, isLike)
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// delta is applied to the counter of the submitted reaction, other to
+	// the opposite one.
+	var delta, other int
+	if inserted == 1 {
+		delta = 1
+	} else {
+		var current bool
+		query = fmt.Sprintf(`SELECT is_like FROM %s WHERE user_id = $1 AND %s = $2 FOR UPDATE`, t.reactionTable, t.idColumn)
+		if err = tx.QueryRow(query, userID, targetID).Scan(&current); err != nil {
+			return err
+		}
+		if current == isLike {
+			query = fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 AND %s = $2`, t.reactionTable, t.idColumn)
+			_, err = tx.Exec(query, userID, targetID)
+			delta = -1
+		} else {
+			query = fmt.Sprintf(`UPDATE %s SET is_like = $1 WHERE user_id = $2 AND %s = $3`, t.reactionTable, t.idColumn)
+			_, err = tx.Exec(query, isLike, userID, targetID)
+			delta, other = 1, -1
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	likeDelta, dislikeDelta := delta, other
+	if !isLike {
+		likeDelta, dislikeDelta = other, delta
+	}
+	query = fmt.Sprintf(`UPDATE %s SET "like" = GREATEST("like" + $1, 0), dislike = GREATEST(dislike + $2, 0) WHERE id = $3`, t.counterTable)
+	if _, err = tx.Exec(query, likeDelta, dislikeDelta, targetID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getReactionsByUser returns the user's reactions to the given targets, keyed
+// by target id. Targets the user has not reacted to are absent.
+func (p *Postgres) getReactionsByUser(t reactionTarget, userID int, targetIDs []int) (map[int]models.Reaction, error) {
+	reactions := make(map[int]models.Reaction)
+	if len(targetIDs) == 0 {
+		return reactions, nil
+	}
+
+	query := fmt.Sprintf(`SELECT %s, is_like FROM %s WHERE user_id = $1 AND %s = ANY($2)`, t.idColumn, t.reactionTable, t.idColumn)
+	rows, err := p.db.Query(query, userID, pq.Array(targetIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID int
+		var isLike bool
+		if err := rows.Scan(&targetID, &isLike); err != nil {
+			return nil, err
+		}
+		reactions[targetID] = models.NewReaction(isLike)
+	}
+	return reactions, rows.Err()
+}
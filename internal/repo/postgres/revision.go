@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+func (p *Postgres) UpdatePost(postID, editorID int, title, content, contentHTML string, categories []int, now time.Time) error {
+	op := "postgres.UpdatePost"
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var authorID int
+	var created time.Time
+	err = tx.QueryRow(`SELECT user_id, created FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, postID).Scan(&authorID, &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var revisions int
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM post_revisions WHERE post_id = $1`, postID).Scan(&revisions); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if revisions == 0 {
+		if err = insertRevision(tx, postID, authorID, created); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE posts SET title = $1, content = $2, content_html = $3, updated = $4 WHERE id = $5`, title, content, contentHTML, now, postID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err = tx.Exec(`DELETE FROM post_category WHERE post_id = $1`, postID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = insertPostCategories(tx, postID, categories); err != nil {
+		if errors.Is(err, models.ErrInvalidCategory) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = insertRevision(tx, postID, editorID, now); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+	return nil
+}
+
+// insertRevision records the current state of the post as a revision.
+func insertRevision(tx *sql.Tx, postID, editorID int, created time.Time) error {
+	stmt := `INSERT INTO post_revisions (post_id, editor_id, title, content, categories, created)
+	SELECT p.id, $1::integer, p.title, p.content,
+		COALESCE((SELECT string_agg(c.name, ', ' ORDER BY c.position, c.id)
+			FROM post_category pc
+			JOIN category c ON pc.category_id = c.id
+			WHERE pc.post_id = p.id), ''),
+		$2::timestamptz
+	FROM posts p WHERE p.id = $3`
+	_, err := tx.Exec(stmt, editorID, created, postID)
+	return err
+}
+
+func (p *Postgres) GetPostRevisions(postID int) ([]models.PostRevision, error) {
+	op := "postgres.GetPostRevisions"
+	stmt := `SELECT r.id, r.post_id, r.editor_id, COALESCE(u.name, ''), r.title, r.content, r.categories, r.created
+	FROM post_revisions r
+	LEFT JOIN users u ON r.editor_id = u.id
+	WHERE r.post_id = $1
+	ORDER BY r.id`
+
+	rows, err := p.db.Query(stmt, postID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var revisions []models.PostRevision
+	for rows.Next() {
+		var r models.PostRevision
+		if err := rows.Scan(&r.ID, &r.PostID, &r.EditorID, &r.EditorName, &r.Title, &r.Content, &r.Categories, &r.Created); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return revisions, nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"fmt"
+	"forum/models"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// postDocument and commentDocument are the text search documents of posts
+// and comments. They must match the expressions of the search indexes in
+// the initial migration, or searches fall back to scanning every row.
+const (
+	postDocument    = `(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B'))`
+	commentDocument = `to_tsvector('simple', content)`
+)
+
+// headlineOptions make ts_headline mark terms as snippet() does in SQLite.
+const headlineOptions = `StartSel="` + models.SnippetMarkStart + `", StopSel="` + models.SnippetMarkEnd + `", MinWords=15, MaxWords=35`
+
+// SearchPosts returns a page of the posts matching q, best matches first,
+// along with the total number of matches. A post matches if its title, its
+// body or one of its comments contains every search term, or a word that
+// starts with it.
+func (p *Postgres) SearchPosts(q models.SearchQuery) ([]models.SearchResult, int, error) {
+	op := "postgres.SearchPosts"
+	terms := searchTerms(q.Text)
+	if len(terms) == 0 {
+		return nil, 0, nil
+	}
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	prefixes := make([]string, len(terms))
+	for i, t := range terms {
+		prefixes[i] = t + ":*"
+	}
+	query, options := arg(strings.Join(prefixes, " & ")), arg(headlineOptions)
+
+	// ts_rank grows with relevance; negating it lets best matches sort first
+	// as with bm25 in SQLite.
+	matches := fmt.Sprintf(`SELECT id, -ts_rank(%[1]s, q), ts_headline('simple', title || ' ' || content, q, %[4]s)
+		FROM posts, to_tsquery('simple', %[3]s) q WHERE %[1]s @@ q
+		UNION ALL
+		SELECT post_id, -ts_rank(%[2]s, q), ts_headline('simple', content, q, %[4]s)
+		FROM comments, to_tsquery('simple', %[3]s) q WHERE %[2]s @@ q`,
+		postDocument, commentDocument, query, options)
+
+	filters := []string{"p.deleted_at IS NULL"}
+	if q.CategoryID > 0 {
+		filters = append(filters, "EXISTS (SELECT 1 FROM post_category pc WHERE pc.post_id = p.id AND pc.category_id = "+arg(q.CategoryID)+")")
+	}
+	if q.Author != "" {
+		filters = append(filters, "lower(u.name) = lower("+arg(q.Author)+")")
+	}
+	if !q.From.IsZero() {
+		filters = append(filters, "p.created >= "+arg(q.From.Format(models.DateLayout))+"::date")
+	}
+	if !q.To.IsZero() {
+		filters = append(filters, "p.created < "+arg(q.To.Format(models.DateLayout))+"::date + 1")
+	}
+
+	from := `WITH matches(post_id, rank, snippet) AS (` + matches + `),
+	best AS (SELECT DISTINCT ON (post_id) post_id, rank, snippet FROM matches ORDER BY post_id, rank)
+	SELECT %s
+	FROM best b
+	JOIN posts p ON p.id = b.post_id
+	JOIN users u ON u.id = p.user_id
+	WHERE ` + strings.Join(filters, " AND ")
+
+	var total int
+	if err := p.db.QueryRow(fmt.Sprintf(from, "COUNT(*)"), args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	stmt := fmt.Sprintf(from, `p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created, p."like", p.dislike, COALESCE(p.image_name, ''), u.name, b.snippet`)
+	stmt += ` ORDER BY b.rank, p.created DESC LIMIT ` + arg(q.PageSize) + ` OFFSET ` + arg(q.Offset())
+	rows, err := p.db.Query(stmt, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.PostID, &r.UserID, &r.Title, &r.Content, &r.ContentHTML, &r.Created, &r.Like, &r.Dislike, &r.ImageName, &r.UserName, &r.Snippet); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return results, total, nil
+}
+
+// searchTerms splits text into the words that are searched for. Everything
+// but letters and digits separates words, so the tsquery syntax cannot be
+// used to inject operators.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+const sessionColumns = `id, user_id, token, created, last_seen, exp_time, user_agent`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(&session.ID, &session.UserID, &session.Token, &session.Created, &session.LastSeen, &session.ExpTime, &session.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (p *Postgres) GetSessionByToken(token string) (*models.Session, error) {
+	op := "postgres.GetSessionByToken"
+	stmt := `SELECT ` + sessionColumns + ` FROM sessions WHERE token = $1`
+
+	session, err := scanSession(p.db.QueryRow(stmt, token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return session, nil
+}
+
+func (p *Postgres) GetSessionsByUserID(userID int) ([]models.Session, error) {
+	op := "postgres.GetSessionsByUserID"
+	stmt := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = $1 ORDER BY last_seen DESC`
+
+	rows, err := p.db.Query(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return sessions, nil
+}
+
+func (p *Postgres) CreateSession(session *models.Session) error {
+	op := "postgres.CreateSession"
+	stmt := `INSERT INTO sessions (user_id, token, created, last_seen, exp_time, user_agent) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err := p.db.QueryRow(stmt, session.UserID, session.Token, session.Created, session.LastSeen, session.ExpTime, session.UserAgent).Scan(&session.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (p *Postgres) RenewSession(token string, lastSeen, expTime time.Time) error {
+	op := "postgres.RenewSession"
+	result, err := p.db.Exec(`UPDATE sessions SET last_seen = $1, exp_time = $2 WHERE token = $3`, lastSeen, expTime, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) DeleteSessionByUserID(userID int) error {
+	op := "postgres.DeleteSessionByUserID"
+	if _, err := p.db.Exec(`DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteSessionByToken(token string) error {
+	op := "postgres.DeleteSessionByToken"
+	if _, err := p.db.Exec(`DELETE FROM sessions WHERE token = $1`, token); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteSessionByID(userID, sessionID int) error {
+	op := "postgres.DeleteSessionByID"
+	result, err := p.db.Exec(`DELETE FROM sessions WHERE id = $1 AND user_id = $2`, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) DeleteExpiredSessions(now time.Time) (int64, error) {
+	op := "postgres.DeleteExpiredSessions"
+	result, err := p.db.Exec(`DELETE FROM sessions WHERE exp_time <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+func (p *Postgres) DeletePost(postID int, now time.Time) error {
+	op := "postgres.DeletePost"
+	result, err := p.db.Exec(`UPDATE posts SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, now, postID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) RestorePost(postID int) error {
+	op := "postgres.RestorePost"
+	result, err := p.db.Exec(`UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`, postID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) GetDeletedPosts() ([]models.Post, error) {
+	op := "postgres.GetDeletedPosts"
+	stmt := `SELECT p.id, p.user_id, p.title, p.content, p.created, p.deleted_at, p."like", p.dislike, COALESCE(p.image_name, ''), COALESCE(u.name, '')
+	FROM posts p
+	LEFT JOIN users u ON p.user_id = u.id
+	WHERE p.deleted_at IS NOT NULL
+	ORDER BY p.deleted_at DESC`
+
+	rows, err := p.db.Query(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		var post models.Post
+		if err := rows.Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.Created, &post.DeletedAt, &post.Like, &post.Dislike, &post.ImageName, &post.UserName); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		posts = append(posts, post)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return posts, nil
+}
+
+// PurgePost permanently removes a trashed post. Foreign keys take its
+// categories, reactions, comments and revisions with it.
+func (p *Postgres) PurgePost(postID int) error {
+	op := "postgres.PurgePost"
+	result, err := p.db.Exec(`DELETE FROM posts WHERE id = $1 AND deleted_at IS NOT NULL`, postID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) PurgeDeletedPosts(before time.Time) (int64, error) {
+	op := "postgres.PurgeDeletedPosts"
+	result, err := p.db.Exec(`DELETE FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,270 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (p *Postgres) GetUserByEmail(email string) (*models.User, error) {
+	op := "postgres.GetUserByEmail"
+	var u models.User
+	var activationSent sql.NullTime
+	stmt := `SELECT id, name, email, created, role, COALESCE(is_activated, FALSE), activation_sent FROM users WHERE email = $1`
+	err := p.db.QueryRow(stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Role, &u.IsActivated, &activationSent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	u.ActivationSent = activationSent.Time
+	return &u, nil
+}
+
+func (p *Postgres) CreateUser(u *models.User) error {
+	op := "postgres.CreateUser"
+	stmt := `INSERT INTO users (name, email, hashed_password, is_activated, activation_token, activation_expires, activation_sent, created)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)`
+	_, err := p.db.Exec(stmt, u.Name, u.Email, string(u.HashedPassword), false, u.ActivationTokenHash, u.ActivationExpires, u.ActivationSent)
+	if err != nil {
+		if isUniqueViolation(err, "users_email_key") {
+			return models.ErrDuplicateEmail
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (p *Postgres) GetUserByID(id int) (*models.User, error) {
+	op := "postgres.GetUserByID"
+	var u models.User
+	stmt := `SELECT id, name, email, COALESCE(pending_email, ''), created, role FROM users WHERE id = $1`
+	err := p.db.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.PendingEmail, &u.Created, &u.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &u, nil
+}
+
+func (p *Postgres) Authenticate(email, password string) (int, error) {
+	op := "postgres.Authenticate"
+	var id int
+	var hashedPassword []byte
+	var isActivated bool
+
+	stmt := `SELECT id, hashed_password, COALESCE(is_activated, FALSE) FROM users WHERE email = $1`
+	err := p.db.QueryRow(stmt, email).Scan(&id, &hashedPassword, &isActivated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrNoRecord
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !isActivated {
+		return 0, models.ErrNotActivated
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// hashPassword returns the bcrypt hash of password as a string, since the
+// driver would store a []byte in its bytea text form.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	return string(hash), err
+}
+
+func (p *Postgres) CheckUserPassword(id int, password string) error {
+	op := "postgres.CheckUserPassword"
+	var hashedPassword []byte
+
+	err := p.db.QueryRow(`SELECT hashed_password FROM users WHERE id = $1`, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (p *Postgres) UpdateUserPassword(id int, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("postgres.UpdateUserPassword: could not hash password: %w", err)
+	}
+
+	_, err = p.db.Exec(`UPDATE users SET hashed_password = $1 WHERE id = $2`, hashedPassword, id)
+	if err != nil {
+		return fmt.Errorf("postgres.UpdateUserPassword: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) UpdateUserEmail(id int, email string) error {
+	_, err := p.db.Exec(`UPDATE users SET email = $1 WHERE id = $2`, email, id)
+	if err != nil {
+		if isUniqueViolation(err, "users_email_key") {
+			return models.ErrDuplicateEmail
+		}
+		return fmt.Errorf("postgres.UpdateUserEmail: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) SetPendingEmail(id int, email, tokenHash string, expires time.Time) error {
+	op := "postgres.SetPendingEmail"
+	stmt := `UPDATE users SET pending_email = $1, activation_token = $2, activation_expires = $3 WHERE id = $4`
+	result, err := p.db.Exec(stmt, email, tokenHash, expires, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) ConfirmPendingEmail(id int) error {
+	op := "postgres.ConfirmPendingEmail"
+	stmt := `UPDATE users SET email = pending_email, pending_email = NULL, activation_token = '', activation_expires = NULL
+	WHERE id = $1 AND pending_email IS NOT NULL`
+	result, err := p.db.Exec(stmt, id)
+	if err != nil {
+		if isUniqueViolation(err, "users_email_key") {
+			return models.ErrDuplicateEmail
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) UpdateUserName(id int, name string) error {
+	_, err := p.db.Exec(`UPDATE users SET name = $1 WHERE id = $2`, name, id)
+	if err != nil {
+		return fmt.Errorf("postgres.UpdateUserName: %w", err)
+	}
+	return nil
+}
+
+// UpdateUserRole changes a user's role. Demoting the last admin gets
+// models.ErrLastAdmin.
+func (p *Postgres) UpdateUserRole(id int, role models.Role) error {
+	op := "postgres.UpdateUserRole"
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if role != models.RoleAdmin {
+		if err = checkLastAdmin(op, tx, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`UPDATE users SET role = $1 WHERE id = $2`, role, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = checkAffected(op, result); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+	return nil
+}
+
+// checkLastAdmin returns models.ErrLastAdmin when user id is the only
+// admin, and models.ErrNoRecord when there is no such user. It locks the
+// admins' rows, so the transaction removing one waits for any other.
+func checkLastAdmin(op string, tx *sql.Tx, id int) error {
+	rows, err := tx.Query(`SELECT id FROM users WHERE role = $1 FOR UPDATE`, models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var admins int
+	isAdmin := false
+	for rows.Next() {
+		var adminID int
+		if err := rows.Scan(&adminID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		admins++
+		isAdmin = isAdmin || adminID == id
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if isAdmin && admins <= 1 {
+		return models.ErrLastAdmin
+	}
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (p *Postgres) GetUserByActivationToken(tokenHash string) (*models.User, error) {
+	op := "postgres.GetUserByActivationToken"
+	var user models.User
+	var expires sql.NullTime
+	query := `SELECT id, name, email, COALESCE(pending_email, ''), COALESCE(is_activated, FALSE), activation_expires
+	FROM users WHERE activation_token = $1`
+	err := p.db.QueryRow(query, tokenHash).Scan(&user.ID, &user.Name, &user.Email, &user.PendingEmail, &user.IsActivated, &expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	user.ActivationExpires = expires.Time
+	return &user, nil
+}
+
+func (p *Postgres) RenewActivationToken(id int, tokenHash string, expires, sent time.Time) error {
+	op := "postgres.RenewActivationToken"
+	stmt := `UPDATE users SET activation_token = $1, activation_expires = $2, activation_sent = $3 WHERE id = $4`
+	result, err := p.db.Exec(stmt, tokenHash, expires, sent, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (p *Postgres) ActivateUser(userID int) error {
+	query := `UPDATE users SET is_activated = TRUE, activation_token = '', activation_expires = NULL WHERE id = $1`
+	_, err := p.db.Exec(query, userID)
+	return err
+}
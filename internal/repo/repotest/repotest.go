@@ -0,0 +1,814 @@
+// Package repotest is a conformance suite for implementations of
+// repo.RepoI. Every storage backend runs the same cases, so the service can
+// rely on them behaving alike.
+package repotest
+
+import (
+	"errors"
+	"forum/internal/repo"
+	"forum/models"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The initial migrations of every backend seed these categories.
+const (
+	technology    = 1
+	entertainment = 2
+	sports        = 3
+	education     = 4
+)
+
+// Run runs every case against a repository returned by open, which must be
+// freshly migrated and hold no data beyond what the migrations seed. open
+// is called once per case.
+func Run(t *testing.T, open func(t *testing.T) repo.RepoI) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, r repo.RepoI)
+	}{
+		{"Users", testUsers},
+		{"PendingEmail", testPendingEmail},
+		{"DeleteUser", testDeleteUser},
+		{"Sessions", testSessions},
+		{"Posts", testPosts},
+		{"Categories", testCategories},
+		{"Reactions", testReactions},
+		{"Comments", testComments},
+		{"Revisions", testRevisions},
+		{"Trash", testTrash},
+		{"Search", testSearch},
+		{"Outbox", testOutbox},
+		{"PasswordReset", testPasswordReset},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, open(t))
+		})
+	}
+}
+
+// newUser creates an activated user with the password "password".
+func newUser(t *testing.T, r repo.RepoI, name, email string) int {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateUser(&models.User{Name: name, Email: email, HashedPassword: hash}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := r.GetUserByEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ActivateUser(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	return u.ID
+}
+
+func newPost(t *testing.T, r repo.RepoI, userID int, title, content string, categories ...int) int {
+	t.Helper()
+	id, err := r.CreatePost(userID, title, content, "<p>"+content+"</p>", "", categories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func newComment(t *testing.T, r repo.RepoI, postID, userID, parentID int, content string) int {
+	t.Helper()
+	id, err := r.CreateComment(&models.Comment{PostID: postID, UserID: userID, ParentID: parentID, Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func newSession(t *testing.T, r repo.RepoI, userID int, lastSeen, expires time.Time) *models.Session {
+	t.Helper()
+	s := models.NewSession(userID, time.Hour, 0)
+	s.LastSeen, s.ExpTime = lastSeen, expires
+	if err := r.CreateSession(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func wantErr(t *testing.T, got, want error) {
+	t.Helper()
+	if !errors.Is(got, want) {
+		t.Errorf("got error %v; want %v", got, want)
+	}
+}
+
+// wantPosts checks that a post list holds the posts titled want, in order,
+// each with the name of its author.
+func wantPosts(t *testing.T, posts *[]models.Post, err error, want ...string) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("got error %v; want posts %q", err, want)
+		return
+	}
+	var got []string
+	for _, p := range *posts {
+		if p.UserName == "" {
+			t.Errorf("got post %q without its author's name", p.Title)
+		}
+		got = append(got, p.Title)
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got posts %q; want %q", got, want)
+	}
+}
+
+func testUsers(t *testing.T, r repo.RepoI) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err := r.CreateUser(&models.User{Name: "alice", Email: "alice@example.com", HashedPassword: hash}); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.CreateUser(&models.User{Name: "alice2", Email: "alice@example.com", HashedPassword: hash}), models.ErrDuplicateEmail)
+
+	u, err := r.GetUserByEmail("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "alice" || u.IsActivated || u.Role != models.RoleUser {
+		t.Errorf("got %+v; want an inactive user named alice", u)
+	}
+	_, err = r.Authenticate("alice@example.com", "password")
+	wantErr(t, err, models.ErrNotActivated)
+
+	if err := r.ActivateUser(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := r.Authenticate("alice@example.com", "password"); err != nil || id != u.ID {
+		t.Errorf("got %d, %v; want %d, nil", id, err, u.ID)
+	}
+	_, err = r.Authenticate("alice@example.com", "wrong")
+	wantErr(t, err, models.ErrInvalidCredentials)
+	_, err = r.Authenticate("nobody@example.com", "password")
+	wantErr(t, err, models.ErrNoRecord)
+	_, err = r.GetUserByID(u.ID + 100)
+	wantErr(t, err, models.ErrNoRecord)
+	_, err = r.GetUserByEmail("nobody@example.com")
+	wantErr(t, err, models.ErrNoRecord)
+
+	if err := r.UpdateUserPassword(u.ID, "new password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CheckUserPassword(u.ID, "new password"); err != nil {
+		t.Errorf("got %v; want nil", err)
+	}
+	wantErr(t, r.CheckUserPassword(u.ID, "password"), models.ErrInvalidCredentials)
+
+	bob := newUser(t, r, "bob", "bob@example.com")
+	wantErr(t, r.UpdateUserEmail(bob, "alice@example.com"), models.ErrDuplicateEmail)
+	if err := r.UpdateUserName(bob, "robert"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.GetUserByID(bob); got == nil || got.Name != "robert" {
+		t.Errorf("got %+v; want name robert", got)
+	}
+
+	if err := r.UpdateUserRole(bob, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.UpdateUserRole(bob+100, models.RoleAdmin), models.ErrNoRecord)
+	wantErr(t, r.UpdateUserRole(bob+100, models.RoleUser), models.ErrNoRecord)
+	wantErr(t, r.UpdateUserRole(bob, models.RoleModerator), models.ErrLastAdmin)
+	if err := r.UpdateUserRole(u.ID, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UpdateUserRole(bob, models.RoleModerator); err != nil {
+		t.Errorf("got %v; want bob demoted while alice is an admin", err)
+	}
+	wantErr(t, r.UpdateUserRole(u.ID, models.RoleUser), models.ErrLastAdmin)
+
+	users, err := r.GetAllUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Errorf("got %d users; want 2, without the deleted user placeholder", len(users))
+	}
+}
+
+func testPendingEmail(t *testing.T, r repo.RepoI) {
+	id := newUser(t, r, "alice", "alice@example.com")
+	newUser(t, r, "bob", "bob@example.com")
+	expires := time.Now().Add(time.Hour)
+
+	if err := r.SetPendingEmail(id, "alice@example.org", "token", expires); err != nil {
+		t.Fatal(err)
+	}
+	u, err := r.GetUserByActivationToken("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != id || u.PendingEmail != "alice@example.org" || !u.IsActivated {
+		t.Errorf("got %+v; want alice with a pending address", u)
+	}
+	if err := r.ConfirmPendingEmail(id); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := r.GetUserByID(id); u == nil || u.Email != "alice@example.org" || u.PendingEmail != "" {
+		t.Errorf("got %+v; want the confirmed address", u)
+	}
+	wantErr(t, r.ConfirmPendingEmail(id), models.ErrNoRecord)
+	_, err = r.GetUserByActivationToken("token")
+	wantErr(t, err, models.ErrNoRecord)
+
+	if err := r.SetPendingEmail(id, "bob@example.com", "token2", expires); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.ConfirmPendingEmail(id), models.ErrDuplicateEmail)
+	wantErr(t, r.SetPendingEmail(id+100, "x@example.com", "token3", expires), models.ErrNoRecord)
+	wantErr(t, r.RenewActivationToken(id+100, "token4", expires, time.Now()), models.ErrNoRecord)
+}
+
+func testDeleteUser(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	bob := newUser(t, r, "bob", "bob@example.com")
+	now := time.Now()
+
+	alicePost := newPost(t, r, alice, "Alice's post", "text", technology)
+	bobPost := newPost(t, r, bob, "Bob's post", "text")
+	comment := newComment(t, r, bobPost, alice, 0, "a comment")
+	if err := r.ReactToPost(alice, bobPost, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReactToComment(alice, comment, false); err != nil {
+		t.Fatal(err)
+	}
+	newSession(t, r, alice, now, now.Add(time.Hour))
+
+	f, err := r.GetUserFootprint(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (models.UserFootprint{Posts: 1, Comments: 1, Reactions: 2, Sessions: 1}); *f != want {
+		t.Errorf("got %+v; want %+v", *f, want)
+	}
+
+	if err := r.DeleteUser(alice); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.DeleteUser(alice), models.ErrNoRecord)
+	_, err = r.GetUserByID(alice)
+	wantErr(t, err, models.ErrNoRecord)
+
+	p, err := r.GetPostByID(alicePost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UserID != models.DeletedUserID || p.UserName != "deleted user" {
+		t.Errorf("got post by %d %q; want it handed to the deleted user", p.UserID, p.UserName)
+	}
+	c, err := r.GetCommentByID(comment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.UserID != models.DeletedUserID || c.Dislike != 0 {
+		t.Errorf("got comment by %d with %d dislikes; want the deleted user and 0", c.UserID, c.Dislike)
+	}
+	if p, _ := r.GetPostByID(bobPost); p == nil || p.Like != 0 {
+		t.Errorf("got %+v; want the like of the deleted user removed", p)
+	}
+	if sessions, err := r.GetSessionsByUserID(alice); err != nil || len(sessions) != 0 {
+		t.Errorf("got %d sessions, %v; want none", len(sessions), err)
+	}
+
+	if err := r.UpdateUserRole(bob, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.DeleteUser(bob), models.ErrLastAdmin)
+	carol := newUser(t, r, "carol", "carol@example.com")
+	if err := r.UpdateUserRole(carol, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteUser(bob); err != nil {
+		t.Errorf("got %v; want bob deleted while carol is still an admin", err)
+	}
+	wantErr(t, r.DeleteUser(carol), models.ErrLastAdmin)
+}
+
+func testSessions(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	bob := newUser(t, r, "bob", "bob@example.com")
+	now := time.Now()
+
+	older := newSession(t, r, alice, now.Add(-time.Hour), now.Add(time.Hour))
+	newer := newSession(t, r, alice, now, now.Add(time.Hour))
+	expired := newSession(t, r, bob, now.Add(-2*time.Hour), now.Add(-time.Minute))
+
+	got, err := r.GetSessionByToken(older.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != older.ID || got.UserID != alice || got.UserAgent != older.UserAgent {
+		t.Errorf("got %+v; want %+v", got, older)
+	}
+	_, err = r.GetSessionByToken("unknown")
+	wantErr(t, err, models.ErrNoRecord)
+
+	sessions, err := r.GetSessionsByUserID(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != newer.ID {
+		t.Errorf("got %+v; want the most recently used session first", sessions)
+	}
+
+	if err := r.RenewSession(older.Token, now.Add(time.Minute), now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.RenewSession("unknown", now, now), models.ErrNoRecord)
+	if sessions, _ := r.GetSessionsByUserID(alice); len(sessions) != 2 || sessions[0].ID != older.ID {
+		t.Errorf("got %+v; want the renewed session first", sessions)
+	}
+
+	wantErr(t, r.DeleteSessionByID(bob, older.ID), models.ErrNoRecord)
+	if n, err := r.DeleteExpiredSessions(now); err != nil || n != 1 {
+		t.Errorf("got %d, %v; want 1 expired session deleted", n, err)
+	}
+	_, err = r.GetSessionByToken(expired.Token)
+	wantErr(t, err, models.ErrNoRecord)
+
+	if err := r.DeleteSessionByID(alice, older.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteSessionByToken(newer.Token); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ := r.GetSessionsByUserID(alice); len(sessions) != 0 {
+		t.Errorf("got %d sessions; want none", len(sessions))
+	}
+}
+
+func testPosts(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	bob := newUser(t, r, "bob", "bob@example.com")
+
+	first := newPost(t, r, alice, "First", "one", technology, sports)
+	newPost(t, r, alice, "Second", "two", entertainment)
+	newPost(t, r, bob, "Third", "three", sports)
+
+	p, err := r.GetPostByID(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "First" || p.Content != "one" || p.ContentHTML != "<p>one</p>" || p.UserName != "alice" || p.Edited() {
+		t.Errorf("got %+v; want alice's unedited first post", p)
+	}
+	_, err = r.GetPostByID(first + 100)
+	wantErr(t, err, models.ErrNoRecord)
+
+	categories, err := r.GetCategoriesByPostID(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 2 || categories[0].ID != technology || categories[1].ID != sports {
+		t.Errorf("got %+v; want technology and sports", categories)
+	}
+
+	posts, err := r.GetAllPostByUserID(alice)
+	wantPosts(t, posts, err, "First", "Second")
+	// Each post is listed once, however many of the categories it is in.
+	posts, err = r.GetAllPostByCategories([]int{sports, technology})
+	wantPosts(t, posts, err, "First", "Third")
+
+	if n, err := r.GetPageNumber(2); err != nil || n != 2 {
+		t.Errorf("got %d pages, %v; want 2", n, err)
+	}
+	for page, want := range map[int][]string{1: {"First", "Second"}, 2: {"Third"}, 3: nil} {
+		posts, err := r.GetAllPostPaginated(page, 2)
+		wantPosts(t, posts, err, want...)
+	}
+
+	wantErr(t, r.AddCategoryToPost(first, []int{education, 999}), models.ErrInvalidCategory)
+	if categories, _ := r.GetCategoriesByPostID(first); len(categories) != 2 {
+		t.Errorf("got %+v; want the failed call to attach nothing", categories)
+	}
+
+	_, err = r.CreatePost(alice, "Fourth", "text", "<p>text</p>", "", []int{technology, 999})
+	wantErr(t, err, models.ErrInvalidCategory)
+	posts, err = r.GetAllPostByUserID(alice)
+	wantPosts(t, posts, err, "First", "Second")
+}
+
+func testCategories(t *testing.T, r repo.RepoI) {
+	all, err := r.GetALLCategory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 || all[0].Slug != "technology" {
+		t.Fatalf("got %+v; want the 4 seeded categories", all)
+	}
+
+	music := &models.Category{Name: "Music", Slug: "music", Description: "Songs"}
+	id, err := r.CreateCategory(music)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.GetCategoryByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "Music" || c.Position != 5 || c.Archived {
+		t.Errorf("got %+v; want Music last in order", c)
+	}
+	_, err = r.CreateCategory(&models.Category{Name: "Music", Slug: "music"})
+	wantErr(t, err, models.ErrDuplicateCategory)
+
+	c.Slug = "sports"
+	wantErr(t, r.UpdateCategory(c), models.ErrDuplicateCategory)
+	c.Slug, c.Position = "tunes", 0
+	if err := r.UpdateCategory(c); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := r.GetALLCategory(); len(all) != 5 || all[0].ID != id {
+		t.Errorf("got %+v; want the moved category first", all)
+	}
+	wantErr(t, r.UpdateCategory(&models.Category{ID: 999, Name: "x", Slug: "x"}), models.ErrNoRecord)
+
+	if err := r.SetCategoryArchived(id, true); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.SetCategoryArchived(999, true), models.ErrNoRecord)
+	_, err = r.GetCategoryByID(999)
+	wantErr(t, err, models.ErrNoRecord)
+
+	alice := newUser(t, r, "alice", "alice@example.com")
+	post := newPost(t, r, alice, "Post", "text")
+	wantErr(t, r.AddCategoryToPost(post, []int{id}), models.ErrInvalidCategory)
+}
+
+func testReactions(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	bob := newUser(t, r, "bob", "bob@example.com")
+	post := newPost(t, r, alice, "Post", "text")
+	other := newPost(t, r, alice, "Other", "text")
+
+	counts := func(wantLike, wantDislike int) {
+		t.Helper()
+		p, err := r.GetPostByID(post)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Like != wantLike || p.Dislike != wantDislike {
+			t.Errorf("got %d likes, %d dislikes; want %d, %d", p.Like, p.Dislike, wantLike, wantDislike)
+		}
+	}
+
+	if err := r.ReactToPost(bob, post, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReactToPost(alice, post, true); err != nil {
+		t.Fatal(err)
+	}
+	counts(2, 0)
+	if err := r.ReactToPost(bob, post, false); err != nil {
+		t.Fatal(err)
+	}
+	counts(1, 1)
+	if err := r.ReactToPost(bob, post, false); err != nil {
+		t.Fatal(err)
+	}
+	counts(1, 0)
+
+	if err := r.ReactToPost(bob, other, false); err != nil {
+		t.Fatal(err)
+	}
+	reactions, err := r.GetPostReactionsByUser(bob, []int{post, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reactions) != 1 || reactions[other] != models.ReactionDislike {
+		t.Errorf("got %v; want only a dislike of the other post", reactions)
+	}
+	if reactions, err := r.GetPostReactionsByUser(bob, nil); err != nil || len(reactions) != 0 {
+		t.Errorf("got %v, %v; want no reactions", reactions, err)
+	}
+
+	liked, err := r.GetLikedPostsByUserID(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*liked) != 1 || (*liked)[0].PostID != post || (*liked)[0].Reaction != models.ReactionLike {
+		t.Errorf("got %+v; want the liked post", *liked)
+	}
+
+	comment := newComment(t, r, post, alice, 0, "a comment")
+	if err := r.ReactToComment(bob, comment, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReactToComment(bob, comment, false); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := r.GetCommentByID(comment); err != nil || c.Like != 0 || c.Dislike != 1 {
+		t.Errorf("got %+v, %v; want 0 likes and 1 dislike", c, err)
+	}
+	if reactions, err := r.GetCommentReactionsByUser(bob, []int{comment}); err != nil || reactions[comment] != models.ReactionDislike {
+		t.Errorf("got %v, %v; want a dislike", reactions, err)
+	}
+}
+
+func testComments(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	post := newPost(t, r, alice, "Post", "text")
+
+	c1 := newComment(t, r, post, alice, 0, "first")
+	c2 := newComment(t, r, post, alice, c1, "reply")
+	c3 := newComment(t, r, post, alice, 0, "second")
+	c4 := newComment(t, r, post, alice, c2, "reply to reply")
+
+	c, err := r.GetCommentByID(c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.PostID != post || c.ParentID != c1 || c.UserName != "alice" || c.Content != "reply" {
+		t.Errorf("got %+v; want alice's reply to %d", c, c1)
+	}
+	_, err = r.GetCommentByID(c4 + 100)
+	wantErr(t, err, models.ErrNoRecord)
+
+	type node struct{ id, depth int }
+	check := func(got []models.Comment, want []node) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("got %d comments; want %d", len(got), len(want))
+		}
+		for i, w := range want {
+			if got[i].CommentID != w.id || got[i].Depth != w.depth {
+				t.Errorf("comment %d: got id %d at depth %d; want id %d at depth %d", i, got[i].CommentID, got[i].Depth, w.id, w.depth)
+			}
+		}
+	}
+
+	comments, err := r.GetCommentsByPostID(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(comments, []node{{c1, 0}, {c2, 1}, {c4, 2}, {c3, 0}})
+
+	thread, err := r.GetCommentThread(c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(thread, []node{{c2, 0}, {c4, 1}})
+
+	_, err = r.GetCommentThread(c4 + 100)
+	wantErr(t, err, models.ErrNoRecord)
+}
+
+func testRevisions(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	bob := newUser(t, r, "bob", "bob@example.com")
+	post := newPost(t, r, alice, "Draft", "before", technology)
+	now := time.Now()
+
+	if err := r.UpdatePost(post, bob, "Final", "after", "<p>after</p>", []int{education, sports}, now); err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.GetPostByID(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Final" || p.ContentHTML != "<p>after</p>" || !p.Edited() {
+		t.Errorf("got %+v; want the edited post", p)
+	}
+
+	revisions, err := r.GetPostRevisions(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions; want the original and the edit", len(revisions))
+	}
+	if rev := revisions[0]; rev.EditorID != alice || rev.Title != "Draft" || rev.Categories != "Technology" {
+		t.Errorf("got %+v; want alice's draft in Technology", rev)
+	}
+	if rev := revisions[1]; rev.EditorID != bob || rev.EditorName != "bob" || rev.Title != "Final" || rev.Categories != "Sports, Education" {
+		t.Errorf("got %+v; want bob's edit in Sports, Education", rev)
+	}
+
+	wantErr(t, r.UpdatePost(post, bob, "Again", "x", "", []int{999}, now), models.ErrInvalidCategory)
+	if revisions, _ := r.GetPostRevisions(post); len(revisions) != 2 {
+		t.Errorf("got %d revisions; want the failed edit rolled back", len(revisions))
+	}
+	wantErr(t, r.UpdatePost(post+100, bob, "x", "x", "", nil, now), models.ErrNoRecord)
+}
+
+func testTrash(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	old := newPost(t, r, alice, "Old", "text", technology)
+	recent := newPost(t, r, alice, "Recent", "text")
+	kept := newPost(t, r, alice, "Kept", "text")
+	comment := newComment(t, r, old, alice, 0, "a comment")
+	if err := r.ReactToPost(alice, old, true); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	if err := r.DeletePost(old, now.Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeletePost(recent, now); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.DeletePost(recent, now), models.ErrNoRecord)
+	_, err := r.GetPostByID(old)
+	wantErr(t, err, models.ErrNoRecord)
+	if n, _ := r.GetPageNumber(10); n != 1 {
+		t.Errorf("got %d pages; want trashed posts left out", n)
+	}
+
+	trash, err := r.GetDeletedPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 2 || trash[0].PostID != recent || trash[0].DeletedAt.IsZero() {
+		t.Errorf("got %+v; want the most recently trashed post first", trash)
+	}
+
+	if err := r.RestorePost(recent); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, r.RestorePost(kept), models.ErrNoRecord)
+	wantErr(t, r.PurgePost(kept), models.ErrNoRecord)
+
+	if n, err := r.PurgeDeletedPosts(now.Add(-24 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("got %d, %v; want the old post purged", n, err)
+	}
+	_, err = r.GetCommentByID(comment)
+	wantErr(t, err, models.ErrNoRecord)
+	if liked, _ := r.GetLikedPostsByUserID(alice); len(*liked) != 0 {
+		t.Errorf("got %+v; want the reaction purged with the post", *liked)
+	}
+
+	if err := r.DeletePost(kept, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PurgePost(kept); err != nil {
+		t.Fatal(err)
+	}
+	if trash, _ := r.GetDeletedPosts(); len(trash) != 0 {
+		t.Errorf("got %+v; want an empty trash", trash)
+	}
+}
+
+func testSearch(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	bob := newUser(t, r, "bob", "bob@example.com")
+
+	gophers := newPost(t, r, alice, "Gophers everywhere", "A post about burrows.", technology)
+	burrows := newPost(t, r, bob, "Underground", "Gopher burrows can be long.", sports)
+	marmots := newPost(t, r, bob, "Mountains", "Nothing to see.", sports)
+	newComment(t, r, marmots, alice, 0, "I saw a gopher here once.")
+	trashed := newPost(t, r, alice, "Trashed gopher", "Gone.")
+	if err := r.DeletePost(trashed, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(q models.SearchQuery) map[int]bool {
+		t.Helper()
+		if q.Page == 0 {
+			q.Page, q.PageSize = 1, 10
+		}
+		results, total, err := r.SearchPosts(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total < len(results) {
+			t.Errorf("got total %d for %d results", total, len(results))
+		}
+		found := make(map[int]bool)
+		for _, res := range results {
+			found[res.PostID] = true
+			if res.Snippet == "" {
+				t.Errorf("post %d: got an empty snippet", res.PostID)
+			}
+		}
+		return found
+	}
+
+	if got := search(models.SearchQuery{Text: "gopher"}); len(got) != 3 || !got[gophers] || !got[burrows] || !got[marmots] {
+		t.Errorf("got %v; want matches in titles, bodies and comments but not the trash", got)
+	}
+	if got := search(models.SearchQuery{Text: "gopher burrows"}); len(got) != 2 || !got[gophers] || !got[burrows] {
+		t.Errorf("got %v; want posts containing both terms", got)
+	}
+	if got := search(models.SearchQuery{Text: "gopher", Author: "BOB", CategoryID: sports}); len(got) != 2 || got[gophers] {
+		t.Errorf("got %v; want bob's posts in sports", got)
+	}
+	if got := search(models.SearchQuery{Text: "gopher", From: time.Now().AddDate(0, 0, 1)}); len(got) != 0 {
+		t.Errorf("got %v; want nothing from tomorrow on", got)
+	}
+	if got := search(models.SearchQuery{Text: "gopher", To: time.Now()}); len(got) != 3 {
+		t.Errorf("got %v; want everything up to today", got)
+	}
+	if got := search(models.SearchQuery{Text: "platypus"}); len(got) != 0 {
+		t.Errorf("got %v; want no matches", got)
+	}
+	if got := search(models.SearchQuery{Text: "*:(|"}); len(got) != 0 {
+		t.Errorf("got %v; want punctuation ignored", got)
+	}
+
+	results, total, err := r.SearchPosts(models.SearchQuery{Text: "gopher", Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(results) != 1 {
+		t.Errorf("got %d results of %d; want the last of 3", len(results), total)
+	}
+}
+
+func testOutbox(t *testing.T, r repo.RepoI) {
+	now := time.Now()
+	due := &models.OutboxMessage{Recipient: "a@example.com", Subject: "Due", Body: "body", NextAttempt: now.Add(-time.Minute), Created: now}
+	later := &models.OutboxMessage{Recipient: "b@example.com", Subject: "Later", Body: "body", NextAttempt: now.Add(time.Hour), Created: now}
+	for _, m := range []*models.OutboxMessage{due, later} {
+		if err := r.EnqueueMail(m); err != nil {
+			t.Fatal(err)
+		}
+		if m.ID == 0 || m.Status != models.OutboxPending {
+			t.Errorf("got %+v; want an id and pending status", m)
+		}
+	}
+
+	messages, err := r.GetDueMail(now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ID != due.ID || messages[0].Subject != "Due" {
+		t.Fatalf("got %+v; want only the due message", messages)
+	}
+
+	if err := r.MarkMailFailed(due.ID, models.OutboxPending, now.Add(-time.Second), "boom"); err != nil {
+		t.Fatal(err)
+	}
+	messages, _ = r.GetDueMail(now, 10)
+	if len(messages) != 1 || messages[0].Attempts != 1 || messages[0].LastError != "boom" {
+		t.Errorf("got %+v; want the failed attempt recorded", messages)
+	}
+
+	if err := r.MarkMailSent(due.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if messages, _ := r.GetDueMail(now.Add(2*time.Hour), 10); len(messages) != 1 || messages[0].ID != later.ID {
+		t.Errorf("got %+v; want only the later message", messages)
+	}
+	wantErr(t, r.MarkMailSent(later.ID+100, now), models.ErrNoRecord)
+	wantErr(t, r.MarkMailFailed(later.ID+100, models.OutboxFailed, now, ""), models.ErrNoRecord)
+}
+
+func testPasswordReset(t *testing.T, r repo.RepoI) {
+	alice := newUser(t, r, "alice", "alice@example.com")
+	now := time.Now()
+	newSession(t, r, alice, now, now.Add(time.Hour))
+
+	reset := &models.PasswordReset{UserID: alice, TokenHash: "hash", Created: now, Expires: now.Add(time.Hour)}
+	if err := r.CreatePasswordReset(reset); err != nil {
+		t.Fatal(err)
+	}
+	expired := &models.PasswordReset{UserID: alice, TokenHash: "expired", Created: now.Add(-2 * time.Hour), Expires: now.Add(-time.Hour)}
+	if err := r.CreatePasswordReset(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.GetPasswordReset("hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != reset.ID || got.UserID != alice || got.Used {
+		t.Errorf("got %+v; want an unused link of alice", got)
+	}
+	_, err = r.GetPasswordReset("unknown")
+	wantErr(t, err, models.ErrNoRecord)
+
+	_, err = r.ResetPassword("expired", now, "new password")
+	wantErr(t, err, models.ErrInvalidToken)
+	_, err = r.ResetPassword("unknown", now, "new password")
+	wantErr(t, err, models.ErrInvalidToken)
+
+	if id, err := r.ResetPassword("hash", now, "new password"); err != nil || id != alice {
+		t.Fatalf("got %d, %v; want %d, nil", id, err, alice)
+	}
+	if _, err := r.Authenticate("alice@example.com", "new password"); err != nil {
+		t.Errorf("got %v; want the new password accepted", err)
+	}
+	if sessions, _ := r.GetSessionsByUserID(alice); len(sessions) != 0 {
+		t.Errorf("got %d sessions; want alice signed out everywhere", len(sessions))
+	}
+	if got, _ := r.GetPasswordReset("hash"); got == nil || !got.Used {
+		t.Errorf("got %+v; want the link used up", got)
+	}
+	_, err = r.ResetPassword("hash", now, "another password")
+	wantErr(t, err, models.ErrInvalidToken)
+}
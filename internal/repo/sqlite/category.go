@@ -1,6 +1,11 @@
 package sqlite
 
-import "fmt"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+)
 
 func (s *Sqlite) AddCategoryToPost(postID int, categories []int) error {
 	const op = "sqlite.AddCategoryToPost"
@@ -9,80 +14,168 @@ func (s *Sqlite) AddCategoryToPost(postID int, categories []int) error {
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	stmt, err := tx.Prepare("INSERT INTO post_category (post_id, category_id) VALUES (?, ?)")
+	if err = insertPostCategories(tx, postID, categories); err != nil {
+		if errors.Is(err, models.ErrInvalidCategory) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// insertPostCategories attaches the post to the given categories within tx.
+// An unknown or archived id is reported as models.ErrInvalidCategory.
+func insertPostCategories(tx *sql.Tx, postID int, categories []int) error {
+	// Selecting from category makes an unknown or archived id insert nothing,
+	// so a stale form can never attach the post to some other category.
+	stmt, err := tx.Prepare(`INSERT INTO post_category (post_id, category_id)
+		SELECT ?, id FROM category WHERE id = ? AND archived = 0`)
 	if err != nil {
-		_ = tx.Rollback()
-		return fmt.Errorf("%s: prepare statement: %w", op, err)
+		return fmt.Errorf("prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, categoryID := range categories {
-		_, err = stmt.Exec(postID, categoryID)
+		result, err := stmt.Exec(postID, categoryID)
 		if err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("%s: exec statement: %w", op, err)
+			return fmt.Errorf("exec statement: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("exec statement: %w", err)
+		}
+		if n != 1 {
+			return models.ErrInvalidCategory
 		}
 	}
-
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("%s: commit transaction: %w", op, err)
-	}
-
 	return nil
 }
 
-func (s *Sqlite) GetALLCategory() ([]string, error) {
+// GetALLCategory returns every category, archived ones included, in display
+// order.
+func (s *Sqlite) GetALLCategory() ([]models.Category, error) {
 	op := "sqlite.GetAllCategory"
-	stmt := `SELECT name FROM category ORDER BY id ASC`
+	stmt := `SELECT id, name, slug, description, position, archived FROM category ORDER BY position ASC, id ASC`
 
 	rows, err := s.db.Query(stmt)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	var categories []string
+	var categories []models.Category
 	for rows.Next() {
-		var categoryName string
-		err := rows.Scan(&categoryName)
+		var c models.Category
+		err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.Description, &c.Position, &c.Archived)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		categories = append(categories, categoryName)
+		categories = append(categories, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-
 	return categories, nil
 }
 
-func CreateCategory(string) error {
-	return nil
+func (s *Sqlite) GetCategoryByID(id int) (*models.Category, error) {
+	op := "sqlite.GetCategoryByID"
+	stmt := `SELECT id, name, slug, description, position, archived FROM category WHERE id = ?`
+
+	var c models.Category
+	err := s.db.QueryRow(stmt, id).Scan(&c.ID, &c.Name, &c.Slug, &c.Description, &c.Position, &c.Archived)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &c, nil
+}
+
+// CreateCategory inserts a category at the end of the display order when no
+// position is given.
+func (s *Sqlite) CreateCategory(c *models.Category) (int, error) {
+	op := "sqlite.CreateCategory"
+
+	if c.Position == 0 {
+		err := s.db.QueryRow(`SELECT COALESCE(MAX(position), 0) + 1 FROM category`).Scan(&c.Position)
+		if err != nil {
+			return -1, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	stmt := `INSERT INTO category (name, slug, description, position) VALUES (?, ?, ?, ?)`
+	result, err := s.db.Exec(stmt, c.Name, c.Slug, c.Description, c.Position)
+	if err != nil {
+		if isUniqueViolation(err, "category.slug") {
+			return -1, models.ErrDuplicateCategory
+		}
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+	return int(id), nil
 }
 
-func (s *Sqlite) GetCategoriesByPostID(postID int) (map[int]string, error) {
-	stmt := `SELECT 
-	category_id, 
-	category.name as name
-	FROM 
-	post_category 
-	INNER JOIN category ON post_category.category_id = category.id
-	WHERE post_id=?`
+func (s *Sqlite) UpdateCategory(c *models.Category) error {
+	op := "sqlite.UpdateCategory"
+	stmt := `UPDATE category SET name = ?, slug = ?, description = ?, position = ? WHERE id = ?`
+
+	result, err := s.db.Exec(stmt, c.Name, c.Slug, c.Description, c.Position, c.ID)
+	if err != nil {
+		if isUniqueViolation(err, "category.slug") {
+			return models.ErrDuplicateCategory
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (s *Sqlite) SetCategoryArchived(id int, archived bool) error {
+	op := "sqlite.SetCategoryArchived"
+	result, err := s.db.Exec(`UPDATE category SET archived = ? WHERE id = ?`, archived, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+func (s *Sqlite) GetCategoriesByPostID(postID int) ([]models.Category, error) {
+	op := "sqlite.GetCategoriesByPostID"
+	stmt := `SELECT c.id, c.name, c.slug, c.description, c.position, c.archived
+	FROM post_category pc
+	INNER JOIN category c ON pc.category_id = c.id
+	WHERE pc.post_id = ?
+	ORDER BY c.position ASC, c.id ASC`
 
 	rows, err := s.db.Query(stmt, postID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	category := make(map[int]string)
+	var categories []models.Category
 	for rows.Next() {
-		var categoryID int
-		var categoryName string
-		err := rows.Scan(&categoryID, &categoryName)
+		var c models.Category
+		err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.Description, &c.Position, &c.Archived)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		category[categoryID] = categoryName
+		categories = append(categories, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return category, nil
+	return categories, nil
 }
@@ -1,23 +1,134 @@
 package sqlite
 
-func (s *Sqlite) CreateComment() error {
-	return nil
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+)
+
+func (s *Sqlite) CreateComment(c *models.Comment) (int, error) {
+	op := "sqlite.CreateComment"
+	const query = `INSERT INTO comments (post_id, user_id, content, content_html, parent_id) VALUES (?, ?, ?, ?, ?)`
+
+	var parentID sql.NullInt64
+	if c.ParentID != 0 {
+		parentID = sql.NullInt64{Int64: int64(c.ParentID), Valid: true}
+	}
+
+	result, err := s.db.Exec(query, c.PostID, c.UserID, c.Content, c.ContentHTML, parentID)
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	commentID, err := result.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(commentID), nil
 }
 
-func (s *Sqlite) GetCommentByPostID() error {
-	return nil
+func (s *Sqlite) GetCommentByID(commentID int) (*models.Comment, error) {
+	op := "sqlite.GetCommentByID"
+	stmt := `SELECT c.id, c.post_id, c.parent_id, c.user_id, u.name, c.content, COALESCE(c.content_html, ''), c.created, c.like, c.dislike
+	FROM comments c
+	JOIN users u ON c.user_id = u.id
+	WHERE c.id = ?`
+
+	var c models.Comment
+	var parentID sql.NullInt64
+	err := s.db.QueryRow(stmt, commentID).Scan(&c.CommentID, &c.PostID, &parentID, &c.UserID, &c.UserName, &c.Content, &c.ContentHTML, &c.Created, &c.Like, &c.Dislike)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	c.ParentID = int(parentID.Int64)
+	return &c, nil
 }
 
-// like system
+// commentTreeQuery walks a comment tree depth-first. The anchor selects the
+// roots; the sort path zero-pads ids so that siblings keep creation order.
+const commentTreeQuery = `WITH RECURSIVE thread(id, depth, path) AS (
+		SELECT id, 0, printf('%%010d', id) FROM comments WHERE %s
+		UNION ALL
+		SELECT c.id, t.depth + 1, t.path || '/' || printf('%%010d', c.id)
+		FROM comments c
+		JOIN thread t ON c.parent_id = t.id
+	)
+	SELECT c.id, c.post_id, c.parent_id, c.user_id, u.name, c.content, COALESCE(c.content_html, ''), c.created, c.like, c.dislike, t.depth
+	FROM thread t
+	JOIN comments c ON c.id = t.id
+	JOIN users u ON c.user_id = u.id
+	ORDER BY t.path`
 
-func (s *Sqlite) AddLikeComment() error {
-	return nil
+// GetCommentsByPostID returns every comment of a post in thread order.
+func (s *Sqlite) GetCommentsByPostID(postID int) ([]models.Comment, error) {
+	op := "sqlite.GetCommentsByPostID"
+	stmt := fmt.Sprintf(commentTreeQuery, "post_id = ? AND parent_id IS NULL")
+
+	comments, err := s.queryCommentTree(stmt, postID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return comments, nil
 }
 
-func (s *Sqlite) AddDisLikeComment() error {
-	return nil
+// GetCommentThread returns the comment with the given id followed by all of
+// its replies in thread order.
+func (s *Sqlite) GetCommentThread(commentID int) ([]models.Comment, error) {
+	op := "sqlite.GetCommentThread"
+	stmt := fmt.Sprintf(commentTreeQuery, "id = ?")
+
+	comments, err := s.queryCommentTree(stmt, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(comments) == 0 {
+		return nil, models.ErrNoRecord
+	}
+	return comments, nil
+}
+
+func (s *Sqlite) queryCommentTree(stmt string, args ...any) ([]models.Comment, error) {
+	rows, err := s.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []models.Comment
+	for rows.Next() {
+		var c models.Comment
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.CommentID, &c.PostID, &parentID, &c.UserID, &c.UserName, &c.Content, &c.ContentHTML, &c.Created, &c.Like, &c.Dislike, &c.Depth); err != nil {
+			return nil, err
+		}
+		c.ParentID = int(parentID.Int64)
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
 }
 
-func (s *Sqlite) DeleteLikeStatusComment() error {
+// ReactToComment toggles the user's reaction on a comment, see toggleReaction.
+func (s *Sqlite) ReactToComment(userID, commentID int, isLike bool) error {
+	if err := s.toggleReaction(commentReactions, userID, commentID, isLike); err != nil {
+		return fmt.Errorf("sqlite.ReactToComment: %w", err)
+	}
 	return nil
 }
+
+// GetCommentReactionsByUser returns the user's reactions to the given
+// comments, keyed by comment id.
+func (s *Sqlite) GetCommentReactionsByUser(userID int, commentIDs []int) (map[int]models.Reaction, error) {
+	reactions, err := s.getReactionsByUser(commentReactions, userID, commentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite.GetCommentReactionsByUser: %w", err)
+	}
+	return reactions, nil
+}
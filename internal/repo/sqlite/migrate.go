@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"forum/models"
+	"forum/pkg/migrate"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+func migrations() fs.FS {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
+
+// Migrator applies the migrations in the migrations directory to a database.
+type Migrator struct {
+	*migrate.Migrator
+	db *sql.DB
+}
+
+// NewMigrator opens the database at storagePath without enforcing foreign
+// keys, so that migrations can rebuild tables other tables refer to. A
+// migration that does so must itself drop or repair rows that would break
+// a reference.
+func NewMigrator(storagePath string) (*Migrator, error) {
+	const op = "sqlite.NewMigrator"
+
+	db, err := open(storagePath, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	m, err := migrate.New(db, migrations())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &Migrator{Migrator: m, db: db}, nil
+}
+
+func (m *Migrator) Up() (int, error) {
+	return m.To(m.Latest())
+}
+
+func (m *Migrator) To(version int) (int, error) {
+	if version > 0 {
+		if err := m.upgradeLegacySchema(); err != nil {
+			return 0, fmt.Errorf("sqlite.Migrator: %w", err)
+		}
+	}
+	return m.Migrator.To(version)
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// upgradeLegacySchema prepares a database created by NewDB before it was
+// migrated, whose tables may lack columns added since. Once the missing
+// columns exist, the initial migration adopts its tables as they are.
+func (m *Migrator) upgradeLegacySchema() error {
+	version, err := m.Version()
+	if err != nil || version > 0 {
+		return err
+	}
+	var legacy int
+	err = m.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'users'`).Scan(&legacy)
+	if err != nil || legacy == 0 {
+		return err
+	}
+
+	columnUpgrades := []struct {
+		table, column, definition string
+	}{
+		{"users", "role", "TEXT NOT NULL DEFAULT 'user'"},
+		{"users", "activation_token", "TEXT"},
+		{"users", "is_activated", "BOOLEAN"},
+		{"users", "pending_email", "TEXT"},
+		{"users", "activation_expires", "TIMESTAMP"},
+		{"users", "activation_sent", "TIMESTAMP"},
+		{"comments", "parent_id", "INTEGER REFERENCES comments(id)"},
+		{"category", "slug", "TEXT"},
+		{"category", "description", "TEXT NOT NULL DEFAULT ''"},
+		{"category", "position", "INTEGER NOT NULL DEFAULT 0"},
+		{"category", "archived", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"sessions", "created", "TIMESTAMP"},
+		{"sessions", "last_seen", "TIMESTAMP"},
+		{"sessions", "user_agent", "TEXT NOT NULL DEFAULT ''"},
+		{"posts", "updated", "TIMESTAMP"},
+		{"posts", "deleted_at", "TIMESTAMP"},
+		{"posts", "content_html", "TEXT"},
+		{"comments", "content_html", "TEXT"},
+	}
+	for _, u := range columnUpgrades {
+		if err := addColumnIfNotExists(m.db, u.table, u.column, u.definition); err != nil {
+			return err
+		}
+	}
+
+	// Sessions issued before created/last_seen existed cannot be renewed;
+	// their owners simply sign in again.
+	if _, err := m.db.Exec(`DELETE FROM sessions WHERE created IS NULL OR last_seen IS NULL`); err != nil {
+		return err
+	}
+	return backfillCategories(m.db)
+}
+
+// backfillCategories fills in slugs and positions for categories created
+// before those columns existed.
+func backfillCategories(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, name, COALESCE(slug, '') FROM category`)
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool)
+	missing := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name, slug string
+		if err := rows.Scan(&id, &name, &slug); err != nil {
+			rows.Close()
+			return err
+		}
+		if slug == "" {
+			missing[id] = name
+		} else {
+			used[slug] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, name := range missing {
+		slug := models.Slugify(name)
+		if used[slug] {
+			slug = fmt.Sprintf("%s-%d", slug, id)
+		}
+		used[slug] = true
+		if _, err := db.Exec(`UPDATE category SET slug = ? WHERE id = ?`, slug, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(`UPDATE category SET position = id WHERE position = 0`)
+	return err
+}
+
+// addColumnIfNotExists brings a table created by an older NewDB up to date,
+// since CREATE TABLE IF NOT EXISTS leaves existing tables untouched.
+// Missing tables are left for the initial migration to create.
+func addColumnIfNotExists(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		found = true
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil || !found {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS comment_user_like;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS post_category;
+DROP TABLE IF EXISTS category;
+DROP TABLE IF EXISTS post_user_Like;
+DROP TABLE IF EXISTS post_revisions;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
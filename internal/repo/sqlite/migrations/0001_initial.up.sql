@@ -0,0 +1,143 @@
+-- The schema as NewDB last created it. Tables and indexes use IF NOT EXISTS
+-- so that databases created before migrations existed can adopt it, see
+-- upgradeLegacySchema.
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL UNIQUE,
+	hashed_password TEXT NOT NULL,
+	created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	status INTEGER DEFAULT 0,
+	role TEXT NOT NULL DEFAULT 'user',
+	activation_token TEXT,
+	is_activated BOOLEAN,
+	pending_email TEXT,
+	activation_expires TIMESTAMP,
+	activation_sent TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER,
+	token TEXT NOT NULL,
+	exp_time TIMESTAMP NOT NULL,
+	created TIMESTAMP,
+	last_seen TIMESTAMP,
+	user_agent TEXT NOT NULL DEFAULT '',
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS posts (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	content_html TEXT,
+	created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	like INTEGER DEFAULT 0,
+	dislike INTEGER DEFAULT 0,
+	image_name TEXT,
+	updated TIMESTAMP,
+	deleted_at TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+	id INTEGER PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts(id),
+	editor_id INTEGER NOT NULL REFERENCES users(id),
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	categories TEXT NOT NULL DEFAULT '',
+	created TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS post_user_Like (
+	user_id INTEGER,
+	post_id INTEGER,
+	is_like BOOLEAN,
+	PRIMARY KEY (user_id, post_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id),
+	FOREIGN KEY (post_id) REFERENCES posts(post_id)
+);
+
+CREATE TABLE IF NOT EXISTS category (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	slug TEXT,
+	description TEXT NOT NULL DEFAULT '',
+	position INTEGER NOT NULL DEFAULT 0,
+	archived BOOLEAN NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS post_category (
+	category_id INTEGER,
+	post_id INTEGER,
+	PRIMARY KEY (category_id, post_id),
+	FOREIGN KEY (category_id) REFERENCES category(category_id),
+	FOREIGN KEY (post_id) REFERENCES posts(post_id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+	id INTEGER PRIMARY KEY,
+	post_id INTEGER,
+	user_id INTEGER,
+	created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	content TEXT NOT NULL,
+	content_html TEXT,
+	like INTEGER DEFAULT 0,
+	dislike INTEGER DEFAULT 0,
+	parent_id INTEGER REFERENCES comments(id),
+	FOREIGN KEY (post_id) REFERENCES posts(post_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS comment_user_like (
+	user_id INTEGER,
+	comment_id INTEGER,
+	is_like BOOLEAN,
+	PRIMARY KEY (user_id, comment_id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (comment_id) REFERENCES comments(id)
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+	id INTEGER PRIMARY KEY,
+	recipient TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	body TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt TIMESTAMP NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	created TIMESTAMP NOT NULL,
+	sent TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	token_hash TEXT NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL,
+	expires TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_category_slug ON category(slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_token ON sessions(token);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_status ON outbox(status);
+CREATE INDEX IF NOT EXISTS idx_post_revisions_post_id ON post_revisions(post_id);
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at);
+
+INSERT INTO category (name, slug, position)
+SELECT name, slug, position FROM (
+	SELECT 'Technology' AS name, 'technology' AS slug, 1 AS position
+	UNION ALL SELECT 'Entertainment', 'entertainment', 2
+	UNION ALL SELECT 'Sports', 'sports', 3
+	UNION ALL SELECT 'Education', 'education', 4
+)
+WHERE NOT EXISTS (SELECT 1 FROM category);
@@ -0,0 +1,124 @@
+-- Restores the tables of 0001_initial. The deleted user placeholder stays,
+-- since posts and comments may belong to it.
+
+CREATE TABLE sessions_old (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER,
+	token TEXT NOT NULL,
+	exp_time TIMESTAMP NOT NULL,
+	created TIMESTAMP,
+	last_seen TIMESTAMP,
+	user_agent TEXT NOT NULL DEFAULT '',
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+INSERT INTO sessions_old SELECT id, user_id, token, exp_time, created, last_seen, user_agent FROM sessions;
+
+CREATE TABLE posts_old (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	content_html TEXT,
+	created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	like INTEGER DEFAULT 0,
+	dislike INTEGER DEFAULT 0,
+	image_name TEXT,
+	updated TIMESTAMP,
+	deleted_at TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+INSERT INTO posts_old
+SELECT id, user_id, title, content, content_html, created, like, dislike, image_name, updated, deleted_at FROM posts;
+
+CREATE TABLE post_revisions_old (
+	id INTEGER PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts(id),
+	editor_id INTEGER NOT NULL REFERENCES users(id),
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	categories TEXT NOT NULL DEFAULT '',
+	created TIMESTAMP NOT NULL
+);
+INSERT INTO post_revisions_old
+SELECT id, post_id, editor_id, title, content, categories, created FROM post_revisions;
+
+CREATE TABLE post_user_Like_old (
+	user_id INTEGER,
+	post_id INTEGER,
+	is_like BOOLEAN,
+	PRIMARY KEY (user_id, post_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id),
+	FOREIGN KEY (post_id) REFERENCES posts(post_id)
+);
+INSERT INTO post_user_Like_old SELECT user_id, post_id, is_like FROM post_user_like;
+
+CREATE TABLE post_category_old (
+	category_id INTEGER,
+	post_id INTEGER,
+	PRIMARY KEY (category_id, post_id),
+	FOREIGN KEY (category_id) REFERENCES category(category_id),
+	FOREIGN KEY (post_id) REFERENCES posts(post_id)
+);
+INSERT INTO post_category_old SELECT category_id, post_id FROM post_category;
+
+CREATE TABLE comments_old (
+	id INTEGER PRIMARY KEY,
+	post_id INTEGER,
+	user_id INTEGER,
+	created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	content TEXT NOT NULL,
+	content_html TEXT,
+	like INTEGER DEFAULT 0,
+	dislike INTEGER DEFAULT 0,
+	parent_id INTEGER REFERENCES comments(id),
+	FOREIGN KEY (post_id) REFERENCES posts(post_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+INSERT INTO comments_old
+SELECT id, post_id, user_id, created, content, content_html, like, dislike, parent_id FROM comments;
+
+CREATE TABLE comment_user_like_old (
+	user_id INTEGER,
+	comment_id INTEGER,
+	is_like BOOLEAN,
+	PRIMARY KEY (user_id, comment_id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (comment_id) REFERENCES comments(id)
+);
+INSERT INTO comment_user_like_old SELECT user_id, comment_id, is_like FROM comment_user_like;
+
+CREATE TABLE password_resets_old (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	token_hash TEXT NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL,
+	expires TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+INSERT INTO password_resets_old
+SELECT id, user_id, token_hash, created, expires, used_at FROM password_resets;
+
+DROP TABLE sessions;
+DROP TABLE posts;
+DROP TABLE post_revisions;
+DROP TABLE post_user_like;
+DROP TABLE post_category;
+DROP TABLE comments;
+DROP TABLE comment_user_like;
+DROP TABLE password_resets;
+
+ALTER TABLE sessions_old RENAME TO sessions;
+ALTER TABLE posts_old RENAME TO posts;
+ALTER TABLE post_revisions_old RENAME TO post_revisions;
+ALTER TABLE post_user_Like_old RENAME TO post_user_Like;
+ALTER TABLE post_category_old RENAME TO post_category;
+ALTER TABLE comments_old RENAME TO comments;
+ALTER TABLE comment_user_like_old RENAME TO comment_user_like;
+ALTER TABLE password_resets_old RENAME TO password_resets;
+
+CREATE UNIQUE INDEX idx_sessions_token ON sessions(token);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_posts_deleted_at ON posts(deleted_at);
+CREATE INDEX idx_post_revisions_post_id ON post_revisions(post_id);
+CREATE INDEX idx_comments_post_id ON comments(post_id);
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
@@ -0,0 +1,144 @@
+-- Points every foreign key at a real column and gives it an ON DELETE
+-- policy. SQLite cannot alter constraints, so each table is rebuilt; rows
+-- that already reference missing users or posts are dropped or, for content
+-- worth keeping, handed to the deleted user placeholder (id 0).
+
+INSERT OR IGNORE INTO users (id, name, email, hashed_password, role, is_activated)
+VALUES (0, 'deleted user', '', '', 'user', 1);
+
+CREATE TABLE sessions_new (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token TEXT NOT NULL,
+	exp_time TIMESTAMP NOT NULL,
+	created TIMESTAMP,
+	last_seen TIMESTAMP,
+	user_agent TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO sessions_new
+SELECT id, user_id, token, exp_time, created, last_seen, user_agent FROM sessions
+WHERE user_id IN (SELECT id FROM users);
+
+CREATE TABLE posts_new (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE SET DEFAULT,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	content_html TEXT,
+	created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	like INTEGER DEFAULT 0,
+	dislike INTEGER DEFAULT 0,
+	image_name TEXT,
+	updated TIMESTAMP,
+	deleted_at TIMESTAMP
+);
+INSERT INTO posts_new
+SELECT id, CASE WHEN user_id IN (SELECT id FROM users) THEN user_id ELSE 0 END,
+	title, content, content_html, created, like, dislike, image_name, updated, deleted_at
+FROM posts;
+
+CREATE TABLE post_revisions_new (
+	id INTEGER PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	editor_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE SET DEFAULT,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	categories TEXT NOT NULL DEFAULT '',
+	created TIMESTAMP NOT NULL
+);
+INSERT INTO post_revisions_new
+SELECT id, post_id, CASE WHEN editor_id IN (SELECT id FROM users) THEN editor_id ELSE 0 END,
+	title, content, categories, created
+FROM post_revisions
+WHERE post_id IN (SELECT id FROM posts);
+
+CREATE TABLE post_user_like_new (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	is_like BOOLEAN,
+	PRIMARY KEY (user_id, post_id)
+);
+INSERT INTO post_user_like_new
+SELECT user_id, post_id, is_like FROM post_user_Like
+WHERE user_id IN (SELECT id FROM users) AND post_id IN (SELECT id FROM posts);
+
+CREATE TABLE post_category_new (
+	category_id INTEGER NOT NULL REFERENCES category(id) ON DELETE CASCADE,
+	post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	PRIMARY KEY (category_id, post_id)
+);
+INSERT INTO post_category_new
+SELECT category_id, post_id FROM post_category
+WHERE category_id IN (SELECT id FROM category) AND post_id IN (SELECT id FROM posts);
+
+CREATE TABLE comments_new (
+	id INTEGER PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL DEFAULT 0 REFERENCES users(id) ON DELETE SET DEFAULT,
+	created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	content TEXT NOT NULL,
+	content_html TEXT,
+	like INTEGER DEFAULT 0,
+	dislike INTEGER DEFAULT 0,
+	parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE
+);
+INSERT INTO comments_new
+SELECT id, post_id, CASE WHEN user_id IN (SELECT id FROM users) THEN user_id ELSE 0 END,
+	created, content, content_html, like, dislike,
+	CASE WHEN parent_id IN (SELECT id FROM comments WHERE post_id IN (SELECT id FROM posts)) THEN parent_id END
+FROM comments
+WHERE post_id IN (SELECT id FROM posts);
+
+CREATE TABLE comment_user_like_new (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+	is_like BOOLEAN,
+	PRIMARY KEY (user_id, comment_id)
+);
+INSERT INTO comment_user_like_new
+SELECT user_id, comment_id, is_like FROM comment_user_like
+WHERE user_id IN (SELECT id FROM users) AND comment_id IN (SELECT id FROM comments_new);
+
+CREATE TABLE password_resets_new (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL,
+	expires TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+INSERT INTO password_resets_new
+SELECT id, user_id, token_hash, created, expires, used_at FROM password_resets
+WHERE user_id IN (SELECT id FROM users);
+
+DROP TABLE sessions;
+DROP TABLE posts;
+DROP TABLE post_revisions;
+DROP TABLE post_user_Like;
+DROP TABLE post_category;
+DROP TABLE comments;
+DROP TABLE comment_user_like;
+DROP TABLE password_resets;
+
+ALTER TABLE sessions_new RENAME TO sessions;
+ALTER TABLE posts_new RENAME TO posts;
+ALTER TABLE post_revisions_new RENAME TO post_revisions;
+ALTER TABLE post_user_like_new RENAME TO post_user_like;
+ALTER TABLE post_category_new RENAME TO post_category;
+ALTER TABLE comments_new RENAME TO comments;
+ALTER TABLE comment_user_like_new RENAME TO comment_user_like;
+ALTER TABLE password_resets_new RENAME TO password_resets;
+
+CREATE UNIQUE INDEX idx_sessions_token ON sessions(token);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_posts_user_id ON posts(user_id);
+CREATE INDEX idx_posts_deleted_at ON posts(deleted_at);
+CREATE INDEX idx_post_revisions_post_id ON post_revisions(post_id);
+CREATE INDEX idx_post_revisions_editor_id ON post_revisions(editor_id);
+CREATE INDEX idx_post_user_like_post_id ON post_user_like(post_id);
+CREATE INDEX idx_post_category_post_id ON post_category(post_id);
+CREATE INDEX idx_comments_post_id ON comments(post_id);
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+CREATE INDEX idx_comments_user_id ON comments(user_id);
+CREATE INDEX idx_comment_user_like_comment_id ON comment_user_like(comment_id);
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
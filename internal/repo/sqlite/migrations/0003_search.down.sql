@@ -0,0 +1,9 @@
+DROP TRIGGER posts_fts_insert;
+DROP TRIGGER posts_fts_delete;
+DROP TRIGGER posts_fts_update;
+DROP TRIGGER comments_fts_insert;
+DROP TRIGGER comments_fts_delete;
+DROP TRIGGER comments_fts_update;
+
+DROP TABLE posts_fts;
+DROP TABLE comments_fts;
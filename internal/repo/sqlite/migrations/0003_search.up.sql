@@ -0,0 +1,41 @@
+-- Full-text indexes of post and comment text, used by search. Both are
+-- external-content FTS5 tables over posts and comments, kept in sync by
+-- triggers. A later migration that rebuilds posts or comments drops these
+-- triggers and must create them again.
+--
+-- Databases that ran an older build may already hold the tables, filled by
+-- triggers the rebuilds of 0002_foreign_keys dropped; they are rebuilt below.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+	title, content,
+	content='posts', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(
+	content,
+	content='comments', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+	INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+	INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+	INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+	INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+	INSERT INTO comments_fts(rowid, content) VALUES (new.id, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+	INSERT INTO comments_fts(comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+	INSERT INTO comments_fts(comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+	INSERT INTO comments_fts(rowid, content) VALUES (new.id, new.content);
+END;
+
+INSERT INTO posts_fts(posts_fts) VALUES ('rebuild');
+INSERT INTO comments_fts(comments_fts) VALUES ('rebuild');
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN status INTEGER DEFAULT 0;
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+func (s *Sqlite) EnqueueMail(m *models.OutboxMessage) error {
+	op := "sqlite.EnqueueMail"
+	stmt := `INSERT INTO outbox (recipient, subject, body, status, next_attempt, created) VALUES (?, ?, ?, ?, ?, ?)`
+
+	result, err := s.db.Exec(stmt, m.Recipient, m.Subject, m.Body, models.OutboxPending, m.NextAttempt, m.Created)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	m.ID = int(id)
+	m.Status = models.OutboxPending
+	return nil
+}
+
+// GetDueMail returns up to limit pending messages whose next attempt is due,
+// oldest first.
+func (s *Sqlite) GetDueMail(now time.Time, limit int) ([]models.OutboxMessage, error) {
+	op := "sqlite.GetDueMail"
+	stmt := `SELECT id, recipient, subject, body, status, attempts, next_attempt, last_error, created
+	FROM outbox
+	WHERE status = ? AND julianday(next_attempt) <= julianday(?)
+	ORDER BY id
+	LIMIT ?`
+
+	rows, err := s.db.Query(stmt, models.OutboxPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var m models.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Recipient, &m.Subject, &m.Body, &m.Status, &m.Attempts, &m.NextAttempt, &m.LastError, &m.Created); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		messages = append(messages, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return messages, nil
+}
+
+func (s *Sqlite) MarkMailSent(id int, sent time.Time) error {
+	op := "sqlite.MarkMailSent"
+	stmt := `UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = '', sent = ? WHERE id = ?`
+
+	result, err := s.db.Exec(stmt, models.OutboxSent, sent, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+// MarkMailFailed records a failed delivery. With status OutboxPending the
+// message is retried at nextAttempt; OutboxFailed gives up on it.
+func (s *Sqlite) MarkMailFailed(id int, status models.OutboxStatus, nextAttempt time.Time, lastError string) error {
+	op := "sqlite.MarkMailFailed"
+	stmt := `UPDATE outbox SET status = ?, attempts = attempts + 1, next_attempt = ?, last_error = ? WHERE id = ?`
+
+	result, err := s.db.Exec(stmt, status, nextAttempt, lastError, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
@@ -16,11 +16,11 @@ func init() {
 }
 
 type PostParse struct {
-	Author     string `json"Omar Sohail"`
+	Author     string `json:"author"`
 	Title      string `json:"title"`
 	Content    string `json:"description"`
 	ImgURL     string `json:"urlToImage"`
-	Categories []int  `-`
+	Categories []int  `json:"-"`
 }
 
 type Form struct {
@@ -59,6 +59,18 @@ func (s *Sqlite) CreateParsePosts() error {
 	if err != nil {
 		return err
 	}
+
+	all, err := s.GetALLCategory()
+	if err != nil {
+		return err
+	}
+	var categoryIDs []int
+	for _, c := range all {
+		if !c.Archived {
+			categoryIDs = append(categoryIDs, c.ID)
+		}
+	}
+
 	for _, post := range *posts {
 		UserID := 1
 		if post.Author != "" {
@@ -71,12 +83,9 @@ func (s *Sqlite) CreateParsePosts() error {
 			}
 		}
 
-		postID, err := s.CreatePost(UserID, post.Title, post.Content, post.ImgURL)
-		if err != nil {
+		if _, err = s.CreatePost(UserID, post.Title, post.Content, "", post.ImgURL, getRandomCategory(categoryIDs, 2)); err != nil {
 			return err
 		}
-		categories := getRandomCategory(2)
-		s.AddCategoryToPost(postID, categories)
 	}
 	return nil
 }
@@ -102,10 +111,14 @@ func getEmail(name string) string {
 	return ""
 }
 
-func getRandomCategory(len int) []int {
-	arr := make([]int, len)
-	for i := range arr {
-		arr[i] = rand.Intn(4) + 1
+// getRandomCategory picks up to n distinct ids from categoryIDs.
+func getRandomCategory(categoryIDs []int, n int) []int {
+	if n > len(categoryIDs) {
+		n = len(categoryIDs)
+	}
+	arr := make([]int, n)
+	for i, j := range rand.Perm(len(categoryIDs))[:n] {
+		arr[i] = categoryIDs[j]
 	}
 	return arr
 }
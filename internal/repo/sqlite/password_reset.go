@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+func (s *Sqlite) CreatePasswordReset(p *models.PasswordReset) error {
+	op := "sqlite.CreatePasswordReset"
+	stmt := `INSERT INTO password_resets (user_id, token_hash, created, expires) VALUES (?, ?, ?, ?)`
+
+	result, err := s.db.Exec(stmt, p.UserID, p.TokenHash, p.Created, p.Expires)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	p.ID = int(id)
+	return nil
+}
+
+func (s *Sqlite) GetPasswordReset(tokenHash string) (*models.PasswordReset, error) {
+	op := "sqlite.GetPasswordReset"
+	stmt := `SELECT id, user_id, token_hash, created, expires, used_at IS NOT NULL
+	FROM password_resets WHERE token_hash = ?`
+
+	var p models.PasswordReset
+	err := s.db.QueryRow(stmt, tokenHash).Scan(&p.ID, &p.UserID, &p.TokenHash, &p.Created, &p.Expires, &p.Used)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &p, nil
+}
+
+// ResetPassword redeems a reset link in one transaction: it sets the new
+// password, uses up every outstanding link of the user and signs them out
+// everywhere. Unknown, used and expired links report models.ErrInvalidToken.
+func (s *Sqlite) ResetPassword(tokenHash string, now time.Time, password string) (int, error) {
+	op := "sqlite.ResetPassword"
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var p models.PasswordReset
+	err = tx.QueryRow(`SELECT user_id, expires, used_at IS NOT NULL FROM password_resets WHERE token_hash = ?`, tokenHash).
+		Scan(&p.UserID, &p.Expires, &p.Used)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidToken
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !p.Usable(now) {
+		return 0, models.ErrInvalidToken
+	}
+
+	queries := []struct {
+		stmt string
+		args []any
+	}{
+		{`UPDATE users SET hashed_password = ? WHERE id = ?`, []any{hashedPassword, p.UserID}},
+		{`UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, []any{now, p.UserID}},
+		{`DELETE FROM sessions WHERE user_id = ?`, []any{p.UserID}},
+	}
+	for _, q := range queries {
+		if _, err = tx.Exec(q.stmt, q.args...); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return p.UserID, nil
+}
@@ -8,10 +8,19 @@ import (
 	"strings"
 )
 
-func (s *Sqlite) CreatePost(userID int, title, content, imageName string) (int, error) {
+// CreatePost inserts a post together with its categories, so that a post
+// is never left without the categories it was published under.
+func (s *Sqlite) CreatePost(userID int, title, content, contentHTML, imageName string, categories []int) (int, error) {
 	op := "sqlite.CreatePost"
-	const query = `INSERT INTO posts (user_id, title, content, image_name) VALUES (?, ?, ?, ?)`
-	result, err := s.db.Exec(query, userID, title, content, imageName)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	const query = `INSERT INTO posts (user_id, title, content, content_html, image_name) VALUES (?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, userID, title, content, contentHTML, imageName)
 	if err != nil {
 		return -1, fmt.Errorf("%s: %w", op, err)
 	}
@@ -21,205 +30,154 @@ func (s *Sqlite) CreatePost(userID int, title, content, imageName string) (int,
 		return -1, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err = insertPostCategories(tx, int(postID), categories); err != nil {
+		if errors.Is(err, models.ErrInvalidCategory) {
+			return -1, err
+		}
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return -1, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
 	return int(postID), nil
 }
 
 func (s *Sqlite) GetPostByID(postID int) (*models.Post, error) {
 	op := "sqlite.GetPostByID"
-	stmt := `SELECT id, user_id, title, content, created, like, dislike, image_name FROM posts WHERE id = ? ORDER BY created asc`
+	stmt := `SELECT p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created, p.updated, p.like, p.dislike, p.image_name, u.name
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.id = ? AND p.deleted_at IS NULL`
 	post := models.Post{}
+	var updated sql.NullTime
 
-	err := s.db.QueryRow(stmt, postID).Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.Created, &post.Like, &post.Dislike, &post.ImageName)
+	err := s.db.QueryRow(stmt, postID).Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.ContentHTML, &post.Created, &updated, &post.Like, &post.Dislike, &post.ImageName, &post.UserName)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	post.Updated = updated.Time
 	return &post, nil
 }
 
-func (s *Sqlite) GetAllPost() ([]models.Post, error) {
-	const query = `SELECT post_id, user_id, title, content, created, like, dislike, image_name FROM Post`
-	rows, err := s.db.Query(query)
-	if err != nil {
-		return nil, err
+// ReactToPost toggles the user's reaction on a post, see toggleReaction.
+func (s *Sqlite) ReactToPost(userID, postID int, isLike bool) error {
+	if err := s.toggleReaction(postReactions, userID, postID, isLike); err != nil {
+		return fmt.Errorf("sqlite.ReactToPost: %w", err)
 	}
-	defer rows.Close()
-
-	var posts []models.Post
-	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.Created, &post.Like, &post.Dislike, &post.ImageName)
-		if err != nil {
-			return nil, err
-		}
-		posts = append(posts, post)
-	}
-	return posts, nil
+	return nil
 }
 
-func (s *Sqlite) AddLikeAndDislike(isLike bool, userID, postID string) error {
-	tx, err := s.db.Begin()
+// GetPostReactionsByUser returns the user's reactions to the given posts,
+// keyed by post id. Posts the user has not reacted to are absent.
+func (s *Sqlite) GetPostReactionsByUser(userID int, postIDs []int) (map[int]models.Reaction, error) {
+	reactions, err := s.getReactionsByUser(postReactions, userID, postIDs)
 	if err != nil {
-		return err
-	}
-
-	// Check if the user has already liked/disliked the post
-	var isExists bool
-	checkQuery := `SELECT EXISTS(SELECT 1 FROM Post_User_Like WHERE user_id = ? AND post_id = ?)`
-	err = tx.QueryRow(checkQuery, userID, postID).Scan(&isExists)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	if !isExists {
-		// Insert like/dislike
-		insertQuery := `INSERT INTO Post_User_Like (user_id, post_id, is_like) VALUES (?, ?, ?)`
-		_, err := tx.Exec(insertQuery, userID, postID, isLike)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
-
-		// Update Post like/dislike count
-		updateQuery := ""
-		if isLike {
-			updateQuery = `UPDATE Post SET like = like + 1 WHERE post_id = ?`
-		} else {
-			updateQuery = `UPDATE Post SET dislike = dislike + 1 WHERE post_id = ?`
-		}
-		_, err = tx.Exec(updateQuery, postID)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
+		return nil, fmt.Errorf("sqlite.GetPostReactionsByUser: %w", err)
 	}
-
-	return tx.Commit()
+	return reactions, nil
 }
 
-func (s *Sqlite) DeleteLikeAndDislike(userID, postID int) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
+func (s *Sqlite) GetLikedPostsByUserID(userID int) (*[]models.Post, error) {
+	op := "sqlite.GetLikedPostsByUserID"
+	stmt := `SELECT ` + postListColumns + `
+	FROM post_user_like pul
+	JOIN posts p ON pul.post_id = p.id
+	JOIN users u ON p.user_id = u.id
+	WHERE pul.user_id = ? AND pul.is_like = 1 AND p.deleted_at IS NULL
+	ORDER BY p.created DESC`
 
-	//is user liked or disliked
-	var isLike bool
-	checkQuery := `SELECT is_like FROM Post_User_Like WHERE user_id = ? AND post_id = ?`
-	err = tx.QueryRow(checkQuery, userID, postID).Scan(&isLike)
+	posts, err := s.queryPosts(stmt, userID)
 	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	// delete the like/dislike
-	deleteQuery := `DELETE FROM Post_User_Like WHERE user_id = ? AND post_id = ?`
-	_, err = tx.Exec(deleteQuery, userID, postID)
-	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-
-	// decrement the like or dislike
-	updateQuery := ""
-	if isLike {
-		updateQuery = `UPDATE Post SET like = like - 1 WHERE post_id = ? AND like > 0`
-	} else {
-		updateQuery = `UPDATE Post SET dislike = dislike - 1 WHERE post_id = ? AND dislike > 0`
+	for i := range posts {
+		posts[i].Reaction = models.ReactionLike
 	}
-	_, err = tx.Exec(updateQuery, postID)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	return tx.Commit()
+	return &posts, nil
 }
 
-func (s *Sqlite) GetAllPostByUserID(userID int) (*[]models.Post, error) {
-	const query = `SELECT id, user_id, title, content, created, like, dislike, image_name FROM posts WHERE user_id=?`
-	rows, err := s.db.Query(query, userID)
+// postListColumns are the columns scanned by queryPosts.
+const postListColumns = `p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created, p.like, p.dislike, COALESCE(p.image_name, ''), u.name`
+
+// queryPosts runs a query selecting postListColumns.
+func (s *Sqlite) queryPosts(stmt string, args ...any) ([]models.Post, error) {
+	rows, err := s.db.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var posts []models.Post
-
 	for rows.Next() {
 		var post models.Post
-		err := rows.Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.Created, &post.Like, &post.Dislike, &post.ImageName)
-		if err != nil {
+		if err := rows.Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.ContentHTML, &post.Created, &post.Like, &post.Dislike, &post.ImageName, &post.UserName); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
 	}
+	return posts, rows.Err()
+}
 
+func (s *Sqlite) GetAllPostByUserID(userID int) (*[]models.Post, error) {
+	op := "sqlite.GetAllPostByUserID"
+	stmt := `SELECT ` + postListColumns + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.user_id = ? AND p.deleted_at IS NULL
+	ORDER BY p.id`
+
+	posts, err := s.queryPosts(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 	return &posts, nil
 }
 
 func (s *Sqlite) GetAllPostByCategories(categoryIDs []int) (*[]models.Post, error) {
-	query := `SELECT p.id, p.user_id, p.title, p.content, p.created, p.like, p.dislike, p.image_name, u.name
-              FROM posts AS p
-              INNER JOIN post_category AS pc ON p.id = pc.post_id
-              JOIN users u ON p.user_id = u.id
-              WHERE pc.category_id IN (?` + strings.Repeat(",?", len(categoryIDs)-1) + `)
-              GROUP BY p.id`
-
-	args := make([]interface{}, len(categoryIDs))
+	op := "sqlite.GetAllPostByCategories"
+	stmt := `SELECT ` + postListColumns + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE EXISTS (SELECT 1 FROM post_category pc WHERE pc.post_id = p.id AND pc.category_id IN (?` + strings.Repeat(",?", len(categoryIDs)-1) + `))
+		AND p.deleted_at IS NULL
+	ORDER BY p.id`
+
+	args := make([]any, len(categoryIDs))
 	for i, id := range categoryIDs {
 		args[i] = id
 	}
 
-	rows, err := s.db.Query(query, args...)
+	posts, err := s.queryPosts(stmt, args...)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var posts []models.Post
-	for rows.Next() {
-		var post models.Post
-		if err := rows.Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.Created, &post.Like, &post.Dislike, &post.ImageName, &post.UserName); err != nil {
-			return nil, err
-		}
-		posts = append(posts, post)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-
 	return &posts, nil
 }
 
 func (s *Sqlite) GetAllPostPaginated(page int, pageSize int) (*[]models.Post, error) {
 	op := "sqlite.GetAllPostPaginated"
 	offset := (page - 1) * pageSize
-	stmt := `SELECT p.id, p.user_id, p.title, p.content, p.created, p.like, p.dislike, p.image_name, u.name 
-	FROM posts p 
-	JOIN users u ON p.user_id = u.id 
+	stmt := `SELECT ` + postListColumns + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.deleted_at IS NULL
+	ORDER BY p.id
 	LIMIT ? OFFSET ?`
 
-	rows, err := s.db.Query(stmt, pageSize, offset)
+	posts, err := s.queryPosts(stmt, pageSize, offset)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	defer rows.Close()
-
-	var posts []models.Post
-	for rows.Next() {
-		var post models.Post
-		if err := rows.Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.Created, &post.Like, &post.Dislike, &post.ImageName, &post.UserName); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		posts = append(posts, post)
-	}
 	return &posts, nil
 }
 
 func (s *Sqlite) GetPageNumber(pageSize int) (int, error) {
 	op := "sqlite.GetPageNumber"
-	stmt := `SELECT COUNT(*) FROM posts`
+	stmt := `SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL`
 
 	var totalPosts int
 	err := s.db.QueryRow(stmt).Scan(&totalPosts)
@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"fmt"
+	"forum/models"
+	"strings"
+)
+
+// reactionTarget names the table holding per-user reactions to one kind of
+// content and the table whose like/dislike counters mirror it.
+type reactionTarget struct {
+	reactionTable string
+	idColumn      string
+	counterTable  string
+}
+
+var (
+	postReactions    = reactionTarget{"post_user_like", "post_id", "posts"}
+	commentReactions = reactionTarget{"comment_user_like", "comment_id", "comments"}
+)
+
+// toggleReaction sets the user's reaction on a post or comment: repeating a
+// reaction removes it, and the opposite reaction replaces it. The counters
+// are updated in the same transaction.
+func (s *Sqlite) toggleReaction(t reactionTarget, userID, targetID int, isLike bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The reaction is inserted first, so that two concurrent first reactions
+	// cannot both find none and then collide on the primary key.
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, %s, is_like) VALUES (?, ?, ?) ON CONFLICT (user_id, %s) DO NOTHING`, t.reactionTable, t.idColumn, t.idColumn)
+	result, err := tx.Exec(query, userID, targetID, isLike)
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// delta is applied to the counter of the submitted reaction, other to
+	// the opposite one.
+	var delta, other int
+	if inserted == 1 {
+		delta = 1
+	} else {
+		var current bool
+		query = fmt.Sprintf(`SELECT is_like FROM %s WHERE user_id = ? AND %s = ?`, t.reactionTable, t.idColumn)
+		if err = tx.QueryRow(query, userID, targetID).Scan(&current); err != nil {
+			return err
+		}
+		if current == isLike {
+			query = fmt.Sprintf(`DELETE FROM %s WHERE user_id = ? AND %s = ?`, t.reactionTable, t.idColumn)
+			_, err = tx.Exec(query, userID, targetID)
+			delta = -1
+		} else {
+			query = fmt.Sprintf(`UPDATE %s SET is_like = ? WHERE user_id = ? AND %s = ?`, t.reactionTable, t.idColumn)
+			_, err = tx.Exec(query, isLike, userID, targetID)
+			delta, other = 1, -1
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	likeDelta, dislikeDelta := delta, other
+	if !isLike {
+		likeDelta, dislikeDelta = other, delta
+	}
+	query = fmt.Sprintf(`UPDATE %s SET like = MAX(like + ?, 0), dislike = MAX(dislike + ?, 0) WHERE id = ?`, t.counterTable)
+	if _, err = tx.Exec(query, likeDelta, dislikeDelta, targetID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getReactionsByUser returns the user's reactions to the given targets, keyed
+// by target id. Targets the user has not reacted to are absent.
+func (s *Sqlite) getReactionsByUser(t reactionTarget, userID int, targetIDs []int) (map[int]models.Reaction, error) {
+	reactions := make(map[int]models.Reaction)
+	if len(targetIDs) == 0 {
+		return reactions, nil
+	}
+
+	query := fmt.Sprintf(`SELECT %s, is_like FROM %s WHERE user_id = ? AND %s IN (?`+strings.Repeat(",?", len(targetIDs)-1)+`)`,
+		t.idColumn, t.reactionTable, t.idColumn)
+
+	args := make([]interface{}, 0, len(targetIDs)+1)
+	args = append(args, userID)
+	for _, id := range targetIDs {
+		args = append(args, id)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID int
+		var isLike bool
+		if err := rows.Scan(&targetID, &isLike); err != nil {
+			return nil, err
+		}
+		reactions[targetID] = models.NewReaction(isLike)
+	}
+	return reactions, rows.Err()
+}
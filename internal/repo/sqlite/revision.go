@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+// UpdatePost replaces the title, content and categories of a post and
+// records the result as a new revision by editorID. Posts published before
+// revisions were kept get their original version recorded first, so every
+// edit can be compared with what it replaced.
+func (s *Sqlite) UpdatePost(postID, editorID int, title, content, contentHTML string, categories []int, now time.Time) error {
+	op := "sqlite.UpdatePost"
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var authorID int
+	var created time.Time
+	err = tx.QueryRow(`SELECT user_id, created FROM posts WHERE id = ? AND deleted_at IS NULL`, postID).Scan(&authorID, &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var revisions int
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM post_revisions WHERE post_id = ?`, postID).Scan(&revisions); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if revisions == 0 {
+		if err = insertRevision(tx, postID, authorID, created); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE posts SET title = ?, content = ?, content_html = ?, updated = ? WHERE id = ?`, title, content, contentHTML, now, postID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err = tx.Exec(`DELETE FROM post_category WHERE post_id = ?`, postID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = insertPostCategories(tx, postID, categories); err != nil {
+		if errors.Is(err, models.ErrInvalidCategory) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = insertRevision(tx, postID, editorID, now); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+	return nil
+}
+
+// insertRevision records the current state of the post as a revision.
+func insertRevision(tx *sql.Tx, postID, editorID int, created time.Time) error {
+	stmt := `INSERT INTO post_revisions (post_id, editor_id, title, content, categories, created)
+	SELECT p.id, ?, p.title, p.content,
+		COALESCE((SELECT GROUP_CONCAT(name, ', ') FROM (
+			SELECT c.name FROM post_category pc
+			JOIN category c ON pc.category_id = c.id
+			WHERE pc.post_id = p.id
+			ORDER BY c.position, c.id)), ''),
+		?
+	FROM posts p WHERE p.id = ?`
+	_, err := tx.Exec(stmt, editorID, created, postID)
+	return err
+}
+
+// GetPostRevisions returns the revisions of a post, oldest first.
+func (s *Sqlite) GetPostRevisions(postID int) ([]models.PostRevision, error) {
+	op := "sqlite.GetPostRevisions"
+	stmt := `SELECT r.id, r.post_id, r.editor_id, COALESCE(u.name, ''), r.title, r.content, r.categories, r.created
+	FROM post_revisions r
+	LEFT JOIN users u ON r.editor_id = u.id
+	WHERE r.post_id = ?
+	ORDER BY r.id`
+
+	rows, err := s.db.Query(stmt, postID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var revisions []models.PostRevision
+	for rows.Next() {
+		var r models.PostRevision
+		if err := rows.Scan(&r.ID, &r.PostID, &r.EditorID, &r.EditorName, &r.Title, &r.Content, &r.Categories, &r.Created); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return revisions, nil
+}
@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"fmt"
+	"forum/models"
+	"strings"
+	"unicode"
+)
+
+// SearchPosts returns a page of the posts matching q, best matches first,
+// along with the total number of matches. A post matches if its title, its
+// body or one of its comments contains every search term.
+func (s *Sqlite) SearchPosts(q models.SearchQuery) ([]models.SearchResult, int, error) {
+	op := "sqlite.SearchPosts"
+	terms := searchTerms(q.Text)
+	if len(terms) == 0 {
+		return nil, 0, nil
+	}
+
+	matches, args := ftsMatches(terms)
+
+	filters := []string{"p.deleted_at IS NULL"}
+	if q.CategoryID > 0 {
+		filters = append(filters, "EXISTS (SELECT 1 FROM post_category pc WHERE pc.post_id = p.id AND pc.category_id = ?)")
+		args = append(args, q.CategoryID)
+	}
+	if q.Author != "" {
+		filters = append(filters, "u.name = ? COLLATE NOCASE")
+		args = append(args, q.Author)
+	}
+	if !q.From.IsZero() {
+		filters = append(filters, "julianday(p.created) >= julianday(?)")
+		args = append(args, q.From.Format(models.DateLayout))
+	}
+	if !q.To.IsZero() {
+		filters = append(filters, "julianday(p.created) < julianday(?, '+1 day')")
+		args = append(args, q.To.Format(models.DateLayout))
+	}
+
+	from := `WITH matches(post_id, rank, snippet) AS (` + matches + `),
+	best AS (SELECT post_id, MIN(rank) AS rank, snippet FROM matches GROUP BY post_id)
+	SELECT %s
+	FROM best b
+	JOIN posts p ON p.id = b.post_id
+	JOIN users u ON u.id = p.user_id
+	WHERE ` + strings.Join(filters, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(fmt.Sprintf(from, "COUNT(*)"), args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	stmt := fmt.Sprintf(from, `p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.created, p.like, p.dislike, COALESCE(p.image_name, ''), u.name, b.snippet`) +
+		` ORDER BY b.rank, p.created DESC LIMIT ? OFFSET ?`
+	rows, err := s.db.Query(stmt, append(args, q.PageSize, q.Offset())...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.PostID, &r.UserID, &r.Title, &r.Content, &r.ContentHTML, &r.Created, &r.Like, &r.Dislike, &r.ImageName, &r.UserName, &r.Snippet); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return results, total, nil
+}
+
+// ftsMatches ranks post and comment matches with bm25, weighting titles ten
+// times higher than bodies.
+func ftsMatches(terms []string) (string, []any) {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"*`
+	}
+	match := strings.Join(quoted, " ")
+
+	query := `SELECT rowid, bm25(posts_fts, 10.0, 1.0), snippet(posts_fts, -1, ?, ?, '…', 24)
+		FROM posts_fts WHERE posts_fts MATCH ?
+		UNION ALL
+		SELECT c.post_id, bm25(comments_fts), snippet(comments_fts, 0, ?, ?, '…', 24)
+		FROM comments_fts JOIN comments c ON c.id = comments_fts.rowid
+		WHERE comments_fts MATCH ?`
+	return query, []any{
+		models.SnippetMarkStart, models.SnippetMarkEnd, match,
+		models.SnippetMarkStart, models.SnippetMarkEnd, match,
+	}
+}
+
+// searchTerms splits text into the words that are searched for. Everything
+// but letters and digits separates words, so the FTS5 query syntax cannot be
+// used to inject operators.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
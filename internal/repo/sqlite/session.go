@@ -5,34 +5,89 @@ import (
 	"errors"
 	"fmt"
 	"forum/models"
+	"time"
 )
 
-func (s *Sqlite) GetUserIDByToken(token string) (int, error) {
-	op := "sqlite.GetUserIDByToken"
-	stmt := `SELECT user_id FROM sessions WHERE token = ?`
-	var userID int
+const sessionColumns = `id, user_id, token, created, last_seen, exp_time, user_agent`
 
-	err := s.db.QueryRow(stmt, token).Scan(&userID)
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(&session.ID, &session.UserID, &session.Token, &session.Created, &session.LastSeen, &session.ExpTime, &session.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *Sqlite) GetSessionByToken(token string) (*models.Session, error) {
+	op := "sqlite.GetSessionByToken"
+	stmt := `SELECT ` + sessionColumns + ` FROM sessions WHERE token = ?`
+
+	session, err := scanSession(s.db.QueryRow(stmt, token))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return -1, models.ErrNoRecord
+			return nil, models.ErrNoRecord
 		}
-		return -1, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// GetSessionsByUserID returns the user's sessions, most recently used first.
+func (s *Sqlite) GetSessionsByUserID(userID int) ([]models.Session, error) {
+	op := "sqlite.GetSessionsByUserID"
+	stmt := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = ? ORDER BY julianday(last_seen) DESC`
+
+	rows, err := s.db.Query(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
-	return userID, nil
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return sessions, nil
 }
 
 func (s *Sqlite) CreateSession(session *models.Session) error {
 	op := "sqlite.CreateSession"
-	stmt := `INSERT INTO sessions(user_id, token, exp_time) VALUES(?, ?, ?)`
-	_, err := s.db.Exec(stmt, session.UserID, session.Token, session.ExpTime)
+	stmt := `INSERT INTO sessions(user_id, token, created, last_seen, exp_time, user_agent) VALUES(?, ?, ?, ?, ?, ?)`
+	result, err := s.db.Exec(stmt, session.UserID, session.Token, session.Created, session.LastSeen, session.ExpTime, session.UserAgent)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	session.ID = int(id)
 	return nil
 }
 
+func (s *Sqlite) RenewSession(token string, lastSeen, expTime time.Time) error {
+	op := "sqlite.RenewSession"
+	stmt := `UPDATE sessions SET last_seen = ?, exp_time = ? WHERE token = ?`
+	result, err := s.db.Exec(stmt, lastSeen, expTime, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
 func (s *Sqlite) DeleteSessionByUserID(userID int) error {
 	op := "sqlite.DeleteSessionByUserID"
 	stmt := `DELETE FROM sessions WHERE user_id = ?`
@@ -51,3 +106,31 @@ func (s *Sqlite) DeleteSessionByToken(token string) error {
 	}
 	return nil
 }
+
+// DeleteSessionByID removes one of the user's sessions. Sessions of other
+// users are reported as models.ErrNoRecord.
+func (s *Sqlite) DeleteSessionByID(userID, sessionID int) error {
+	op := "sqlite.DeleteSessionByID"
+	stmt := `DELETE FROM sessions WHERE id = ? AND user_id = ?`
+	result, err := s.db.Exec(stmt, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+// DeleteExpiredSessions removes every session that expired before now and
+// returns how many were deleted.
+func (s *Sqlite) DeleteExpiredSessions(now time.Time) (int64, error) {
+	op := "sqlite.DeleteExpiredSessions"
+	stmt := `DELETE FROM sessions WHERE julianday(exp_time) <= julianday(?)`
+	result, err := s.db.Exec(stmt, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
@@ -2,112 +2,106 @@ package sqlite
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"forum/models"
+	"forum/pkg/migrate"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
 )
 
 type Sqlite struct {
 	db *sql.DB
 }
 
+// ErrNoFTS5 is returned when go-sqlite3 was compiled without the FTS5
+// module, which the search indexes of the migrations need.
+var ErrNoFTS5 = errors.New("sqlite: SQLite lacks the FTS5 module; build with -tags sqlite_fts5")
+
+// NewDB opens the database at storagePath. It refuses a database whose
+// schema does not match the migrations of this build; see NewMigrator.
 func NewDB(storagePath string) (*Sqlite, error) {
 	const op = "storage.sqlite.New"
 
-	db, err := sql.Open("sqlite3", storagePath)
+	db, err := open(storagePath, true)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	tableCreationQueries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY,
-			name TEXT NOT NULL,
-			email TEXT NOT NULL UNIQUE,
-			hashed_password TEXT NOT NULL,
-			created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			status INTEGER DEFAULT 0
-		);`,
-		`CREATE TABLE IF NOT EXISTS sessions (
-			id INTEGER PRIMARY KEY,
-			user_id INTEGER,
-			token TEXT NOT NULL,
-			exp_time TIMESTAMP NOT NULL,
-			FOREIGN KEY (user_id) REFERENCES users(user_id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS posts (
-			id INTEGER PRIMARY KEY,
-			user_id INTEGER,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
-			created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			like INTEGER DEFAULT 0,
-			dislike INTEGER DEFAULT 0,
-			image_name TEXT,
-			FOREIGN KEY (user_id) REFERENCES users(user_id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS post_user_Like (
-			user_id INTEGER,
-			post_id INTEGER,
-			is_like BOOLEAN,
-			PRIMARY KEY (user_id, post_id),
-			FOREIGN KEY (user_id) REFERENCES users(user_id),
-			FOREIGN KEY (post_id) REFERENCES posts(post_id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS category (
-			id INTEGER PRIMARY KEY,
-			name TEXT NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS post_category (
-			category_id INTEGER,
-			post_id INTEGER,
-			PRIMARY KEY (category_id, post_id),
-			FOREIGN KEY (category_id) REFERENCES category(category_id),
-			FOREIGN KEY (post_id) REFERENCES posts(post_id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS comments (
-			id INTEGER PRIMARY KEY,
-			post_id INTEGER,
-			user_id INTEGER,
-			created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			content TEXT NOT NULL,
-			like INTEGER DEFAULT 0,
-			dislike INTEGER DEFAULT 0,
-			FOREIGN KEY (post_id) REFERENCES posts(post_id),
-			FOREIGN KEY (user_id) REFERENCES users(user_id)
-		);`,
-	}
-
-	for _, query := range tableCreationQueries {
-		stmt, err := db.Prepare(query)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		_, err = stmt.Exec()
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		stmt.Close()
-	}
-
-	// defaultCategories := []string{"Technology", "Entertainment", "Sports", "Education"}
-	// for _, category := range defaultCategories {
-	// 	insertQuery := `INSERT INTO category (name) VALUES (?)`
-	// 	stmt, err := db.Prepare(insertQuery)
-	// 	if err != nil {
-	// 		return nil, fmt.Errorf("%s: %w", op, err)
-	// 	}
-	// 	_, err = stmt.Exec(category)
-	// 	if err != nil {
-	// 		return nil, fmt.Errorf("%s: %w", op, err)
-	// 	}
-	// 	stmt.Close()
-	// }
+	m, err := migrate.New(db, migrations())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = m.Check(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
 	return &Sqlite{db: db}, nil
 }
+
+// NewMemory opens a private in-memory database migrated to the latest
+// version. Its data is lost when it is closed, which suits tests and trying
+// the forum out.
+func NewMemory() (*Sqlite, error) {
+	const op = "sqlite.NewMemory"
+
+	db, err := open(":memory:", false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// Every connection would open a database of its own, so the pool keeps
+	// just one. Migrations run before foreign keys are enforced, as with
+	// NewMigrator.
+	db.SetMaxOpenConns(1)
+
+	m, err := migrate.New(db, migrations())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err = m.Up(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err = db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Sqlite{db: db}, nil
+}
+
+// open opens the database at storagePath with foreign key enforcement set
+// on every connection of the pool. It fails with ErrNoFTS5 rather than let
+// a build without FTS5 fall short of the schema.
+func open(storagePath string, foreignKeys bool) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(storagePath, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s%s_foreign_keys=%t", storagePath, sep, foreignKeys))
+	if err != nil {
+		return nil, err
+	}
+
+	var fts5 bool
+	if err = db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if !fts5 {
+		db.Close()
+		return nil, ErrNoFTS5
+	}
+	return db, nil
+}
+
 func (s *Sqlite) GetAllUsers() ([]*models.User, error) {
 	var users []*models.User
-	rows, err := s.db.Query("SELECT id, name, email, hashed_password, created, status FROM users")
+	rows, err := s.db.Query("SELECT id, name, email, hashed_password, created, role FROM users WHERE id <> ?", models.DeletedUserID)
 	if err != nil {
 		return nil, err
 	}
@@ -115,16 +109,91 @@ func (s *Sqlite) GetAllUsers() ([]*models.User, error) {
 
 	for rows.Next() {
 		var u models.User
-		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.HashedPassword, &u.Created, &u.Status)
+		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.HashedPassword, &u.Created, &u.Role)
 		if err != nil {
 			return nil, err
 		}
 		users = append(users, &u)
 	}
 
-	return users, nil
+	return users, rows.Err()
 }
+
+// DeleteUser deletes a user. Foreign keys hand their posts, comments and
+// revisions to the deleted user placeholder and remove their sessions,
+// password resets and reactions; the counters of the posts and comments
+// they reacted to are lowered first. The last admin cannot be deleted, and
+// gets models.ErrLastAdmin.
 func (s *Sqlite) DeleteUser(id int) error {
-	_, err := s.db.Exec("DELETE FROM users WHERE id = ?", id)
-	return err
+	op := "sqlite.DeleteUser"
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = checkLastAdmin(op, tx, id); err != nil {
+		return err
+	}
+
+	for _, t := range []reactionTarget{postReactions, commentReactions} {
+		query := fmt.Sprintf(`UPDATE %[1]s SET
+			like = MAX(like - (SELECT COUNT(*) FROM %[2]s r WHERE r.%[3]s = %[1]s.id AND r.user_id = ? AND r.is_like), 0),
+			dislike = MAX(dislike - (SELECT COUNT(*) FROM %[2]s r WHERE r.%[3]s = %[1]s.id AND r.user_id = ? AND NOT r.is_like), 0)
+		WHERE id IN (SELECT %[3]s FROM %[2]s WHERE user_id = ?)`, t.counterTable, t.reactionTable, t.idColumn)
+		if _, err = tx.Exec(query, id, id, id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = checkAffected(op, result); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+	return nil
+}
+
+// GetUserFootprint counts the content and sessions DeleteUser would affect.
+func (s *Sqlite) GetUserFootprint(id int) (*models.UserFootprint, error) {
+	op := "sqlite.GetUserFootprint"
+	stmt := `SELECT
+		(SELECT COUNT(*) FROM posts WHERE user_id = ?),
+		(SELECT COUNT(*) FROM comments WHERE user_id = ?),
+		(SELECT COUNT(*) FROM post_user_like WHERE user_id = ?) + (SELECT COUNT(*) FROM comment_user_like WHERE user_id = ?),
+		(SELECT COUNT(*) FROM sessions WHERE user_id = ?)`
+
+	var f models.UserFootprint
+	if err := s.db.QueryRow(stmt, id, id, id, id, id).Scan(&f.Posts, &f.Comments, &f.Reactions, &f.Sessions); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &f, nil
+}
+
+// isUniqueViolation reports whether err is a UNIQUE constraint failure on the
+// given "table.column". SQLite names the columns only in the message.
+func isUniqueViolation(err error, column string) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		sqliteErr.Error() == "UNIQUE constraint failed: "+column
+}
+
+// checkAffected turns an UPDATE or DELETE that matched no rows into
+// models.ErrNoRecord.
+func checkAffected(op string, result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
 }
@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"fmt"
+	"forum/models"
+	"time"
+)
+
+// DeletePost moves a post to the trash. Trashed posts are left out of every
+// listing until they are restored or purged.
+func (s *Sqlite) DeletePost(postID int, now time.Time) error {
+	op := "sqlite.DeletePost"
+	result, err := s.db.Exec(`UPDATE posts SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, now, postID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+// RestorePost takes a post out of the trash.
+func (s *Sqlite) RestorePost(postID int) error {
+	op := "sqlite.RestorePost"
+	result, err := s.db.Exec(`UPDATE posts SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, postID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+// GetDeletedPosts returns the posts in the trash, most recently deleted
+// first.
+func (s *Sqlite) GetDeletedPosts() ([]models.Post, error) {
+	op := "sqlite.GetDeletedPosts"
+	stmt := `SELECT p.id, p.user_id, p.title, p.content, p.created, p.deleted_at, p.like, p.dislike, p.image_name, COALESCE(u.name, '')
+	FROM posts p
+	LEFT JOIN users u ON p.user_id = u.id
+	WHERE p.deleted_at IS NOT NULL
+	ORDER BY p.deleted_at DESC`
+
+	rows, err := s.db.Query(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		var post models.Post
+		if err := rows.Scan(&post.PostID, &post.UserID, &post.Title, &post.Content, &post.Created, &post.DeletedAt, &post.Like, &post.Dislike, &post.ImageName, &post.UserName); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		posts = append(posts, post)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return posts, nil
+}
+
+// PurgePost permanently removes a trashed post. Foreign keys take its
+// categories, reactions, comments and revisions with it.
+func (s *Sqlite) PurgePost(postID int) error {
+	op := "sqlite.PurgePost"
+	result, err := s.db.Exec(`DELETE FROM posts WHERE id = ? AND deleted_at IS NOT NULL`, postID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+// PurgeDeletedPosts permanently removes every post trashed at or before
+// before, as PurgePost does, and returns how many were removed.
+func (s *Sqlite) PurgeDeletedPosts(before time.Time) (int64, error) {
+	op := "sqlite.PurgeDeletedPosts"
+	result, err := s.db.Exec(`DELETE FROM posts
+	WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
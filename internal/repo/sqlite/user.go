@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"forum/models"
-	"github.com/google/uuid"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -13,8 +13,9 @@ import (
 func (s *Sqlite) GetUserByEmail(email string) (*models.User, error) {
 	op := "sqlite.GetUserByEmail"
 	var u models.User
-	stmt := `SELECT id, name, email, created FROM users WHERE id=?`
-	err := s.db.QueryRow(stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.Created)
+	var activationSent sql.NullTime
+	stmt := `SELECT id, name, email, created, role, COALESCE(is_activated, 0), activation_sent FROM users WHERE email=?`
+	err := s.db.QueryRow(stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Role, &u.IsActivated, &activationSent)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -22,6 +23,7 @@ func (s *Sqlite) GetUserByEmail(email string) (*models.User, error) {
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	u.ActivationSent = activationSent.Time
 	return &u, nil
 
 }
@@ -30,7 +32,7 @@ func (s *Sqlite) CreateUserAndReturnID(u models.User) (int64, error) {
 	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES (?, ?, ?, CURRENT_TIMESTAMP)`
 	result, err := s.db.Exec(stmt, u.Name, u.Email, string(u.HashedPassword))
 	if err != nil {
-		if err.Error() == "UNIQUE constraint failed: users.email" {
+		if isUniqueViolation(err, "users.email") {
 			return 0, models.ErrDuplicateEmail
 		}
 		return 0, fmt.Errorf("%s: %w", op, err)
@@ -44,12 +46,12 @@ func (s *Sqlite) CreateUserAndReturnID(u models.User) (int64, error) {
 }
 
 func (s *Sqlite) CreateUser(u *models.User) error {
-	u.ActivationToken = generateActivationToken()
 	op := "sqlite.CreateUser"
-	stmt := `INSERT INTO users (name, email, hashed_password, is_activated, activation_token, created) VALUES(?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
-	_, err := s.db.Exec(stmt, u.Name, u.Email, string(u.HashedPassword), false, u.ActivationToken)
+	stmt := `INSERT INTO users (name, email, hashed_password, is_activated, activation_token, activation_expires, activation_sent, created)
+	VALUES(?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+	_, err := s.db.Exec(stmt, u.Name, u.Email, string(u.HashedPassword), false, u.ActivationTokenHash, u.ActivationExpires, u.ActivationSent)
 	if err != nil {
-		if err.Error() == "UNIQUE constraint failed: users.email" {
+		if isUniqueViolation(err, "users.email") {
 			return models.ErrDuplicateEmail
 		}
 		return fmt.Errorf("%s: %w", op, err)
@@ -60,8 +62,8 @@ func (s *Sqlite) CreateUser(u *models.User) error {
 func (s *Sqlite) GetUserByID(id int) (*models.User, error) {
 	op := "sqlite.GetUserByID"
 	var u models.User
-	stmt := `SELECT id, name, email, created FROM users WHERE id=?`
-	err := s.db.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created)
+	stmt := `SELECT id, name, email, COALESCE(pending_email, ''), created, role FROM users WHERE id=?`
+	err := s.db.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.PendingEmail, &u.Created, &u.Role)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
@@ -100,8 +102,36 @@ func (s *Sqlite) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
+func hashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// CheckUserPassword compares password with the stored hash of the user,
+// reporting a mismatch as models.ErrInvalidCredentials.
+func (s *Sqlite) CheckUserPassword(id int, password string) error {
+	op := "sqlite.CheckUserPassword"
+	var hashedPassword []byte
+
+	err := s.db.QueryRow(`SELECT hashed_password FROM users WHERE id = ?`, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
 func (s *Sqlite) UpdateUserPassword(id int, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	hashedPassword, err := hashPassword(password)
 	if err != nil {
 		return fmt.Errorf("sqlite.UpdateUserPassword: could not hash password: %w", err)
 	}
@@ -118,7 +148,7 @@ func (s *Sqlite) UpdateUserEmail(id int, email string) error {
 	stmt := `UPDATE users SET email = ? WHERE id = ?`
 	_, err := s.db.Exec(stmt, email, id)
 	if err != nil {
-		if err.Error() == "UNIQUE constraint failed: users.email" {
+		if isUniqueViolation(err, "users.email") {
 			return models.ErrDuplicateEmail
 		}
 		return fmt.Errorf("sqlite.UpdateUserEmail: %w", err)
@@ -126,6 +156,34 @@ func (s *Sqlite) UpdateUserEmail(id int, email string) error {
 	return nil
 }
 
+// SetPendingEmail stores an address the user wants to switch to, together
+// with the hash of the token that confirms it.
+func (s *Sqlite) SetPendingEmail(id int, email, tokenHash string, expires time.Time) error {
+	op := "sqlite.SetPendingEmail"
+	stmt := `UPDATE users SET pending_email = ?, activation_token = ?, activation_expires = ? WHERE id = ?`
+	result, err := s.db.Exec(stmt, email, tokenHash, expires, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
+// ConfirmPendingEmail makes the pending address the user's email and uses up
+// the token that confirmed it.
+func (s *Sqlite) ConfirmPendingEmail(id int) error {
+	op := "sqlite.ConfirmPendingEmail"
+	stmt := `UPDATE users SET email = pending_email, pending_email = NULL, activation_token = '', activation_expires = NULL
+	WHERE id = ? AND pending_email IS NOT NULL`
+	result, err := s.db.Exec(stmt, id)
+	if err != nil {
+		if isUniqueViolation(err, "users.email") {
+			return models.ErrDuplicateEmail
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
 func (s *Sqlite) UpdateUserName(id int, name string) error {
 	stmt := `UPDATE users SET name = ? WHERE id = ?`
 	_, err := s.db.Exec(stmt, name, id)
@@ -134,22 +192,90 @@ func (s *Sqlite) UpdateUserName(id int, name string) error {
 	}
 	return nil
 }
-func generateActivationToken() string {
-	return uuid.New().String()
+
+// UpdateUserRole changes a user's role. Demoting the last admin gets
+// models.ErrLastAdmin.
+func (s *Sqlite) UpdateUserRole(id int, role models.Role) error {
+	op := "sqlite.UpdateUserRole"
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if role != models.RoleAdmin {
+		if err = checkLastAdmin(op, tx, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = checkAffected(op, result); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+	return nil
+}
+
+// checkLastAdmin returns models.ErrLastAdmin when user id is the only
+// admin, and models.ErrNoRecord when there is no such user. It runs in the
+// transaction that removes the admin: SQLite admits one writer at a time,
+// so of two transactions that counted the same admins only one can commit.
+func checkLastAdmin(op string, tx *sql.Tx, id int) error {
+	var role models.Role
+	var admins int
+	query := `SELECT role, (SELECT COUNT(*) FROM users WHERE role = ?) FROM users WHERE id = ?`
+	if err := tx.QueryRow(query, models.RoleAdmin, id).Scan(&role, &admins); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if role == models.RoleAdmin && admins <= 1 {
+		return models.ErrLastAdmin
+	}
+	return nil
 }
 
-func (s *Sqlite) GetUserByActivationToken(token string) (*models.User, error) {
+func (s *Sqlite) GetUserByActivationToken(tokenHash string) (*models.User, error) {
+	op := "sqlite.GetUserByActivationToken"
 	var user models.User
-	query := `SELECT id, name, email, is_activated FROM users WHERE activation_token = ?`
-	err := s.db.QueryRow(query, token).Scan(&user.ID, &user.Name, &user.Email, &user.IsActivated)
+	var expires sql.NullTime
+	query := `SELECT id, name, email, COALESCE(pending_email, ''), COALESCE(is_activated, 0), activation_expires
+	FROM users WHERE activation_token = ?`
+	err := s.db.QueryRow(query, tokenHash).Scan(&user.ID, &user.Name, &user.Email, &user.PendingEmail, &user.IsActivated, &expires)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	// Tokens issued before expiry was tracked count as expired.
+	user.ActivationExpires = expires.Time
 	return &user, nil
 }
 
+// RenewActivationToken replaces the hash of the user's activation token, as
+// when a new activation link is requested.
+func (s *Sqlite) RenewActivationToken(id int, tokenHash string, expires, sent time.Time) error {
+	op := "sqlite.RenewActivationToken"
+	stmt := `UPDATE users SET activation_token = ?, activation_expires = ?, activation_sent = ? WHERE id = ?`
+	result, err := s.db.Exec(stmt, tokenHash, expires, sent, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return checkAffected(op, result)
+}
+
 func (s *Sqlite) ActivateUser(userID int) error {
-	query := `UPDATE users SET is_activated = 1, activation_token = '' WHERE id = ?`
+	query := `UPDATE users SET is_activated = 1, activation_token = '', activation_expires = NULL WHERE id = ?`
 	_, err := s.db.Exec(query, userID)
 	return err
 }
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"forum/models"
+	"forum/pkg/token"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func (s *service) UpdateUserName(userID int, name string) error {
+	return s.repo.UpdateUserName(userID, name)
+}
+
+// RequestEmailChange stores newEmail as pending and mails a confirmation link
+// to it through the activation token. The current address is told about the
+// request, so a hijacked session cannot move the account away unnoticed.
+func (s *service) RequestEmailChange(userID int, currentPassword, newEmail string) error {
+	if err := s.repo.CheckUserPassword(userID, currentPassword); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(user.Email, newEmail) {
+		return models.ErrDuplicateEmail
+	}
+	if _, err = s.repo.GetUserByEmail(newEmail); err == nil {
+		return models.ErrDuplicateEmail
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		return err
+	}
+
+	secret, err := token.New()
+	if err != nil {
+		return err
+	}
+	if err = s.repo.SetPendingEmail(userID, newEmail, token.Hash(secret), time.Now().Add(activationTokenTTL)); err != nil {
+		return err
+	}
+
+	link := s.absoluteURL("/account/email/confirm", url.Values{"token": {secret}})
+	body := fmt.Sprintf("To use this address for your forum account, please click on the following link: %s", link)
+	if err = s.queueMail(newEmail, "Confirm Your New Email", body); err != nil {
+		return err
+	}
+
+	notice := fmt.Sprintf("Someone asked to change the email of your forum account to %s. "+
+		"Nothing changes until the new address is confirmed. "+
+		"If this was not you, change your password right away.", newEmail)
+	return s.queueMail(user.Email, "Email Change Requested", notice)
+}
+
+// ConfirmEmailChange switches the account to the pending address that the
+// token was sent to.
+func (s *service) ConfirmEmailChange(secret string) error {
+	user, err := s.userByActivationToken(secret)
+	if err != nil {
+		return err
+	}
+	if user.PendingEmail == "" {
+		return models.ErrInvalidToken
+	}
+
+	return s.repo.ConfirmPendingEmail(user.ID)
+}
@@ -1,10 +1,70 @@
 package service
 
-func (s *service) GetAllCategory() ([]string, error) {
+import (
+	"forum/models"
+)
 
+// GetAllCategory returns the categories users can pick from, in display order.
+func (s *service) GetAllCategory() ([]models.Category, error) {
 	categories, err := s.repo.GetALLCategory()
 	if err != nil {
 		return nil, err
 	}
-	return categories, nil
+
+	active := make([]models.Category, 0, len(categories))
+	for _, c := range categories {
+		if !c.Archived {
+			active = append(active, c)
+		}
+	}
+	return active, nil
+}
+
+// GetAllCategoryForAdmin returns every category, archived ones included.
+func (s *service) GetAllCategoryForAdmin() ([]models.Category, error) {
+	return s.repo.GetALLCategory()
+}
+
+func (s *service) GetCategoryByID(id int) (*models.Category, error) {
+	return s.repo.GetCategoryByID(id)
+}
+
+func (s *service) CreateCategory(c *models.Category) (int, error) {
+	if c.Slug == "" {
+		c.Slug = models.Slugify(c.Name)
+	}
+	return s.repo.CreateCategory(c)
+}
+
+func (s *service) UpdateCategory(c *models.Category) error {
+	if c.Slug == "" {
+		c.Slug = models.Slugify(c.Name)
+	}
+	return s.repo.UpdateCategory(c)
+}
+
+func (s *service) ArchiveCategory(id int, archived bool) error {
+	return s.repo.SetCategoryArchived(id, archived)
+}
+
+// validateCategories checks that every id belongs to an active category.
+func (s *service) validateCategories(ids []int) error {
+	if len(ids) == 0 {
+		return models.ErrInvalidCategory
+	}
+
+	categories, err := s.GetAllCategory()
+	if err != nil {
+		return err
+	}
+	active := make(map[int]bool, len(categories))
+	for _, c := range categories {
+		active[c.ID] = true
+	}
+	for _, id := range ids {
+		if !active[id] {
+			return models.ErrInvalidCategory
+		}
+	}
+	return nil
 }
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"forum/models"
+	"forum/pkg/markdown"
+)
+
+func (s *service) CreateComment(postID, userID, parentID int, content string) (int, error) {
+	if _, err := s.repo.GetPostByID(postID); err != nil {
+		return 0, err
+	}
+
+	if parentID != 0 {
+		parent, err := s.repo.GetCommentByID(parentID)
+		if err != nil {
+			return 0, err
+		}
+		if parent.PostID != postID {
+			return 0, models.ErrNoRecord
+		}
+	}
+
+	content = models.StripSnippetMarks(content)
+	comment := &models.Comment{
+		PostID:      postID,
+		ParentID:    parentID,
+		UserID:      userID,
+		Content:     content,
+		ContentHTML: markdown.Render(content),
+	}
+	return s.repo.CreateComment(comment)
+}
+
+func (s *service) GetCommentsByPostID(postID int) ([]models.Comment, error) {
+	comments, err := s.repo.GetCommentsByPostID(postID)
+	if err != nil {
+		return nil, err
+	}
+	return limitCommentDepth(comments, s.commentMaxDepth()), nil
+}
+
+func (s *service) GetCommentThread(postID, commentID int) ([]models.Comment, error) {
+	comments, err := s.repo.GetCommentThread(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comments[0].PostID != postID {
+		return nil, models.ErrNoRecord
+	}
+	return limitCommentDepth(comments, s.commentMaxDepth()), nil
+}
+
+func (s *service) GetCommentByID(commentID int) (*models.Comment, error) {
+	return s.repo.GetCommentByID(commentID)
+}
+
+// ReactToComment toggles the user's reaction on a comment. Comments of posts
+// in the trash are reported as models.ErrNoRecord, like the posts.
+func (s *service) ReactToComment(commentID, userID int, reaction models.Reaction) error {
+	if reaction == models.ReactionNone {
+		return models.ErrInvalidReaction
+	}
+	comment, err := s.repo.GetCommentByID(commentID)
+	if err != nil {
+		return err
+	}
+	if _, err = s.repo.GetPostByID(comment.PostID); err != nil {
+		return err
+	}
+	return s.repo.ReactToComment(userID, commentID, reaction.IsLike())
+}
+
+func (s *service) GetCommentReactions(userID int, commentIDs []int) (map[int]models.Reaction, error) {
+	return s.repo.GetCommentReactionsByUser(userID, commentIDs)
+}
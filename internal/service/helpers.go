@@ -1,8 +1,44 @@
 package service
 
-func AddCategory(arr []int) []int {
-	for i, nb := range arr {
-		arr[i] = nb + 1
+import (
+	"forum/models"
+	"strings"
+)
+
+// limitCommentDepth drops comments nested deeper than maxDepth from a list in
+// thread order and flags their closest visible ancestor, so the page can link
+// to the rest of the thread instead.
+func limitCommentDepth(comments []models.Comment, maxDepth int) []models.Comment {
+	visible := make([]models.Comment, 0, len(comments))
+	for _, c := range comments {
+		if c.Depth > maxDepth {
+			visible[len(visible)-1].HasHiddenReplies = true
+			continue
+		}
+		visible = append(visible, c)
+	}
+	return visible
+}
+
+// uniqueIDs returns ids without duplicates, keeping the first occurrence
+// order. The argument is left untouched.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// truncate cuts value to at most max bytes without leaving a broken rune at
+// the end.
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
 	}
-	return arr
+	return strings.ToValidUTF8(value[:max], "")
 }
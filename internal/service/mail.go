@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"forum/models"
+	"forum/pkg/mailer"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL = "http://localhost:8080"
+
+	mailPollInterval = 30 * time.Second
+	mailBatchSize    = 20
+	maxMailAttempts  = 8
+	mailRetryBase    = time.Minute
+	mailRetryMax     = 6 * time.Hour
+)
+
+// queueMail stores a message in the outbox and wakes the delivery loop, so
+// callers never wait on, or fail because of, the mail server.
+func (s *service) queueMail(to, subject, body string) error {
+	now := time.Now()
+	err := s.repo.EnqueueMail(&models.OutboxMessage{
+		Recipient:   to,
+		Subject:     subject,
+		Body:        body,
+		NextAttempt: now,
+		Created:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case s.mailQueued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// RunMailer delivers queued mail until ctx is cancelled. It wakes up when
+// mail is queued and otherwise polls for messages whose retry is due.
+func (s *service) RunMailer(ctx context.Context, errLog *log.Logger) {
+	ticker := time.NewTicker(mailPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.deliverDueMail(time.Now(), errLog); err != nil {
+			errLog.Printf("mailer: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.mailQueued:
+		}
+	}
+}
+
+// deliverDueMail sends every message due at now. Failed messages are retried
+// with exponential backoff and given up after maxMailAttempts.
+func (s *service) deliverDueMail(now time.Time, errLog *log.Logger) error {
+	for {
+		messages, err := s.repo.GetDueMail(now, mailBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range messages {
+			sendErr := s.mailer.Send(mailer.Message{To: m.Recipient, Subject: m.Subject, Body: m.Body})
+			if sendErr == nil {
+				err = s.repo.MarkMailSent(m.ID, time.Now())
+			} else {
+				attempts := m.Attempts + 1
+				status := models.OutboxPending
+				if attempts >= maxMailAttempts {
+					status = models.OutboxFailed
+				}
+				errLog.Printf("mailer: message %d to %s, attempt %d: %v", m.ID, m.Recipient, attempts, sendErr)
+				err = s.repo.MarkMailFailed(m.ID, status, time.Now().Add(mailBackoff(attempts)), sendErr.Error())
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(messages) < mailBatchSize {
+			return nil
+		}
+	}
+}
+
+// mailBackoff doubles the wait after each failed attempt, up to mailRetryMax.
+func mailBackoff(attempts int) time.Duration {
+	delay := mailRetryBase
+	for i := 1; i < attempts && delay < mailRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > mailRetryMax {
+		delay = mailRetryMax
+	}
+	return delay
+}
+
+// absoluteURL builds a link to path on the public site, for use in email.
+func (s *service) absoluteURL(path string, query url.Values) string {
+	base := defaultBaseURL
+	if s.cfg != nil && s.cfg.BaseURL != "" {
+		base = s.cfg.BaseURL
+	}
+
+	link := strings.TrimRight(base, "/") + path
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
@@ -1,47 +1,127 @@
 package service
 
 import (
+	"context"
+	"forum/internal/config"
 	"forum/internal/repo"
 	"forum/models"
+	"forum/pkg/mailer"
+	"forum/pkg/media"
+	"io"
+	"io/fs"
+	"log"
+	"time"
 )
 
+const defaultCommentMaxDepth = 5
+
 type service struct {
-	repo repo.RepoI
+	repo       repo.RepoI
+	cfg        *config.Config
+	mailer     mailer.Mailer
+	media      media.Storage
+	mailQueued chan struct{}
 }
 
 type ServiceI interface {
 	UserServiceI
+	SessionServiceI
 	CategoryServiceI
 	PostServiceI
+	CommentServiceI
+	MailServiceI
+	MediaServiceI
 	GetAllUsers() ([]models.User, error)
-	DeleteUser(int) error
+	DeleteUser(admin *models.User, userID int) error
+	PreviewUserDeletion(userID int) (*models.User, *models.UserFootprint, error)
+	UpdateUserRole(userID int, role models.Role) error
+	EnsureAdmin(email string) error
 	ActivateUser(token string) error
+	ResendActivation(email string) error
 }
 
 type UserServiceI interface {
 	GetUser(int) *models.User
 	CreateUser(*models.User) error
-	Authenticate(string, string) (*models.Session, error)
+	Authenticate(email, password, userAgent string) (*models.Session, error)
+	UpdateUserPassword(userID int, currentPassword, newPassword string) error
+	RequestPasswordReset(email string) error
+	CheckPasswordResetToken(token string) error
+	ResetPassword(token, newPassword string) error
+	UpdateUserName(userID int, name string) error
+	RequestEmailChange(userID int, currentPassword, newEmail string) error
+	ConfirmEmailChange(token string) error
+}
+
+type SessionServiceI interface {
 	DeleteSession(string) error
-	UpdateUserPassword(token string, newPassword string) error
-	GetUserByToken(token string) (*models.User, error)
+	ResolveSession(token string) (*models.User, *models.Session, error)
+	GetSessions(userID int, currentToken string) ([]models.Session, error)
+	RevokeSession(userID, sessionID int) error
+	RevokeAllSessions(userID int) error
+	RunSessionSweeper(ctx context.Context, interval time.Duration, errLog *log.Logger)
+}
+
+type MailServiceI interface {
+	RunMailer(ctx context.Context, errLog *log.Logger)
+}
+
+type MediaServiceI interface {
+	OpenMedia(name string) (fs.File, error)
+	MaxImageSize() int64
 }
 
 type PostServiceI interface {
-	CreatePost(string, string, string, []int) (int, error)
+	CreatePost(userID int, title, content string, categories []int, image io.Reader) (int, error)
 	GetPostByID(int) (*models.Post, error)
+	UpdatePost(editor *models.User, postID int, title, content string, categories []int) error
+	GetPostRevisions(postID int) ([]models.PostRevision, error)
+	DeletePost(user *models.User, postID int) error
+	RestorePost(postID int) error
+	GetDeletedPosts() ([]models.Post, error)
+	PurgePost(postID int) error
+	RunTrashPurger(ctx context.Context, interval time.Duration, errLog *log.Logger)
 	GetAllPostPaginated(int, int) (*[]models.Post, error)
 	GetPageNumber(int) (int, error)
 	GetAllPostByCategories(categories []int) (*[]models.Post, error)
-	GetAllPostByUser(token string) (*[]models.Post, error)
+	GetAllPostByUser(userID int) (*[]models.Post, error)
+	ReactToPost(postID, userID int, reaction models.Reaction) error
+	GetPostReactions(userID int, postIDs []int) (map[int]models.Reaction, error)
+	GetLikedPostsByUser(userID int) (*[]models.Post, error)
+	SearchPosts(models.SearchQuery) ([]models.SearchResult, int, error)
+}
+
+type CommentServiceI interface {
+	CreateComment(postID, userID, parentID int, content string) (int, error)
+	GetCommentsByPostID(int) ([]models.Comment, error)
+	GetCommentThread(postID, commentID int) ([]models.Comment, error)
+	GetCommentByID(int) (*models.Comment, error)
+	ReactToComment(commentID, userID int, reaction models.Reaction) error
+	GetCommentReactions(userID int, commentIDs []int) (map[int]models.Reaction, error)
 }
 
 type CategoryServiceI interface {
-	GetAllCategory() ([]string, error)
+	GetAllCategory() ([]models.Category, error)
+	GetAllCategoryForAdmin() ([]models.Category, error)
+	GetCategoryByID(int) (*models.Category, error)
+	CreateCategory(*models.Category) (int, error)
+	UpdateCategory(*models.Category) error
+	ArchiveCategory(id int, archived bool) error
 }
 
-func New(r repo.RepoI) ServiceI {
+func New(r repo.RepoI, cfg *config.Config, m mailer.Mailer, store media.Storage) ServiceI {
 	return &service{
-		r,
+		repo:       r,
+		cfg:        cfg,
+		mailer:     m,
+		media:      store,
+		mailQueued: make(chan struct{}, 1),
+	}
+}
+
+func (s *service) commentMaxDepth() int {
+	if s.cfg == nil || s.cfg.CommentMaxDepth <= 0 {
+		return defaultCommentMaxDepth
 	}
+	return s.cfg.CommentMaxDepth
 }
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"forum/models"
+	"forum/pkg/media"
+	"io"
+	"io/fs"
+)
+
+const defaultMaxImageSize = 5 << 20
+
+// saveImage validates an uploaded image and stores it with its thumbnail,
+// returning the name to keep with the post. Rejected uploads report
+// models.ErrImageTooLarge or models.ErrInvalidImage.
+func (s *service) saveImage(r io.Reader) (string, error) {
+	img, err := media.Process(r, s.MaxImageSize())
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			return "", models.ErrImageTooLarge
+		case errors.Is(err, media.ErrUnsupported):
+			return "", models.ErrInvalidImage
+		}
+		return "", err
+	}
+
+	if err = s.media.Save(img.Name, img.Data); err != nil {
+		return "", err
+	}
+	if err = s.media.Save(media.ThumbnailName(img.Name), img.Thumbnail); err != nil {
+		return "", err
+	}
+	return img.Name, nil
+}
+
+// OpenMedia opens a stored image or thumbnail by name. Unknown names report
+// fs.ErrNotExist.
+func (s *service) OpenMedia(name string) (fs.File, error) {
+	return s.media.Open(name)
+}
+
+// MaxImageSize is the largest image upload accepted, in bytes.
+func (s *service) MaxImageSize() int64 {
+	if s.cfg == nil || s.cfg.MaxUploadBytes <= 0 {
+		return defaultMaxImageSize
+	}
+	return s.cfg.MaxUploadBytes
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"forum/models"
+	"forum/pkg/token"
+	"net/url"
+	"time"
+)
+
+const passwordResetTTL = time.Hour
+
+// RequestPasswordReset emails a one-time reset link to the account with the
+// given address. Unknown addresses are silently ignored so that the form
+// cannot be used to find out who is registered.
+func (s *service) RequestPasswordReset(email string) error {
+	user, err := s.repo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+
+	secret, err := token.New()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = s.repo.CreatePasswordReset(&models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: token.Hash(secret),
+		Created:   now,
+		Expires:   now.Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.absoluteURL("/password/reset", url.Values{"token": {secret}})
+	body := fmt.Sprintf("Someone asked to reset the password of your account. "+
+		"If it was you, follow this link within %s: %s\n\n"+
+		"If it was not, you can ignore this email.", passwordResetTTL, link)
+	return s.queueMail(user.Email, "Reset Your Password", body)
+}
+
+// CheckPasswordResetToken reports models.ErrInvalidToken unless secret is a
+// reset link that can still be redeemed.
+func (s *service) CheckPasswordResetToken(secret string) error {
+	reset, err := s.repo.GetPasswordReset(token.Hash(secret))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return models.ErrInvalidToken
+		}
+		return err
+	}
+	if !reset.Usable(time.Now()) {
+		return models.ErrInvalidToken
+	}
+	return nil
+}
+
+// ResetPassword redeems a reset link and signs the user out everywhere.
+func (s *service) ResetPassword(secret, newPassword string) error {
+	_, err := s.repo.ResetPassword(token.Hash(secret), time.Now(), newPassword)
+	return err
+}
@@ -2,23 +2,32 @@ package service
 
 import (
 	"forum/models"
+	"forum/pkg/markdown"
+	"io"
 )
 
-func (s *service) CreatePost(title, content, token string, categories []int) (int, error) {
-	userID, err := s.repo.GetUserIDByToken(token)
-	if err != nil {
+// CreatePost publishes a post. image may be nil; otherwise it is validated
+// and stored, see saveImage.
+func (s *service) CreatePost(userID int, title, content string, categories []int, image io.Reader) (int, error) {
+	categoryIDs := uniqueIDs(categories)
+	if err := s.validateCategories(categoryIDs); err != nil {
 		return 0, err
 	}
 
-	postID, err := s.repo.CreatePost(userID, title, content, "Nan")
-	if err != nil {
-		return 0, err
+	var imageName string
+	if image != nil {
+		var err error
+		if imageName, err = s.saveImage(image); err != nil {
+			return 0, err
+		}
 	}
 
-	if err = s.repo.AddCategoryToPost(postID, AddCategory(categories)); err != nil {
+	title, content = models.StripSnippetMarks(title), models.StripSnippetMarks(content)
+	postID, err := s.repo.CreatePost(userID, title, content, markdown.Render(content), imageName, categoryIDs)
+	if err != nil {
 		return 0, err
 	}
-	return postID, err
+	return postID, nil
 }
 
 func (s *service) GetPostByID(id int) (*models.Post, error) {
@@ -32,6 +41,12 @@ func (s *service) GetPostByID(id int) (*models.Post, error) {
 		return nil, err
 	}
 	post.Categories = categories
+
+	comments, err := s.GetCommentsByPostID(id)
+	if err != nil {
+		return nil, err
+	}
+	post.Comments = comments
 	return post, nil
 }
 
@@ -51,7 +66,12 @@ func (s *service) GetPageNumber(pageSize int) (int, error) {
 }
 
 func (s *service) GetAllPostByCategories(categories []int) (*[]models.Post, error) {
-	posts, err := s.repo.GetAllPostByCategories(AddCategory(categories))
+	categoryIDs := uniqueIDs(categories)
+	if err := s.validateCategories(categoryIDs); err != nil {
+		return nil, err
+	}
+
+	posts, err := s.repo.GetAllPostByCategories(categoryIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -62,11 +82,7 @@ func (s *service) GetAllPostByCategories(categories []int) (*[]models.Post, erro
 	return posts, nil
 }
 
-func (s *service) GetAllPostByUser(token string) (*[]models.Post, error) {
-	userID, err := s.repo.GetUserIDByToken(token)
-	if err != nil {
-		return nil, err
-	}
+func (s *service) GetAllPostByUser(userID int) (*[]models.Post, error) {
 	posts, err := s.repo.GetAllPostByUserID(userID)
 
 	if err != nil {
@@ -89,3 +105,28 @@ func (s *service) getCategoryToPost(posts *[]models.Post) error {
 	}
 	return nil
 }
+
+func (s *service) ReactToPost(postID, userID int, reaction models.Reaction) error {
+	if reaction == models.ReactionNone {
+		return models.ErrInvalidReaction
+	}
+	if _, err := s.repo.GetPostByID(postID); err != nil {
+		return err
+	}
+	return s.repo.ReactToPost(userID, postID, reaction.IsLike())
+}
+
+func (s *service) GetPostReactions(userID int, postIDs []int) (map[int]models.Reaction, error) {
+	return s.repo.GetPostReactionsByUser(userID, postIDs)
+}
+
+func (s *service) GetLikedPostsByUser(userID int) (*[]models.Post, error) {
+	posts, err := s.repo.GetLikedPostsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.getCategoryToPost(posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"forum/models"
+	"forum/pkg/markdown"
+	"strings"
+	"time"
+)
+
+// UpdatePost applies an edit by editor, who must be the post's author or a
+// moderator; anyone else gets models.ErrForbidden.
+func (s *service) UpdatePost(editor *models.User, postID int, title, content string, categories []int) error {
+	post, err := s.repo.GetPostByID(postID)
+	if err != nil {
+		return err
+	}
+	if !post.EditableBy(editor) {
+		return models.ErrForbidden
+	}
+
+	categoryIDs := uniqueIDs(categories)
+	if err = s.validateCategories(categoryIDs); err != nil {
+		return err
+	}
+	title, content = models.StripSnippetMarks(title), models.StripSnippetMarks(content)
+	return s.repo.UpdatePost(postID, editor.ID, title, content, markdown.Render(content), categoryIDs, time.Now())
+}
+
+// GetPostRevisions returns the revisions of a post, oldest first. A post that
+// was never edited has a single revision: the one published.
+func (s *service) GetPostRevisions(postID int) ([]models.PostRevision, error) {
+	revisions, err := s.repo.GetPostRevisions(postID)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) > 0 {
+		return revisions, nil
+	}
+
+	post, err := s.repo.GetPostByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.repo.GetCategoriesByPostID(postID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(categories))
+	for i, c := range categories {
+		names[i] = c.Name
+	}
+
+	return []models.PostRevision{{
+		PostID:     post.PostID,
+		EditorID:   post.UserID,
+		EditorName: post.UserName,
+		Title:      post.Title,
+		Content:    post.Content,
+		Categories: strings.Join(names, ", "),
+		Created:    post.Created,
+	}}, nil
+}
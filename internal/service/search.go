@@ -0,0 +1,28 @@
+package service
+
+import "forum/models"
+
+const defaultSearchPageSize = 10
+
+// SearchPosts runs a full-text search and returns the requested page of
+// results with their categories, along with the total number of matches.
+func (s *service) SearchPosts(q models.SearchQuery) ([]models.SearchResult, int, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = defaultSearchPageSize
+	}
+	results, total, err := s.repo.SearchPosts(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range results {
+		categories, err := s.repo.GetCategoriesByPostID(results[i].PostID)
+		if err != nil {
+			return nil, 0, err
+		}
+		results[i].Categories = categories
+	}
+	return results, total, nil
+}
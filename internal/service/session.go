@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"forum/models"
+	"log"
+	"time"
+)
+
+const (
+	defaultSessionTTL = 7 * 24 * time.Hour
+	// sessionRenewInterval limits how often activity is written back, so
+	// that browsing does not cost a database write per request.
+	sessionRenewInterval = time.Minute
+	maxUserAgentLength   = 255
+)
+
+func (s *service) DeleteSession(token string) error {
+	if err := s.repo.DeleteSessionByToken(token); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ResolveSession returns the owner of a session together with the session,
+// renewed for the current request. Unknown and expired sessions both report
+// models.ErrNoRecord; expired ones are removed on the way.
+func (s *service) ResolveSession(token string) (*models.User, *models.Session, error) {
+	session, err := s.repo.GetSessionByToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if session.Expired(now) {
+		if err = s.repo.DeleteSessionByToken(token); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, models.ErrNoRecord
+	}
+
+	if now.Sub(session.LastSeen) >= sessionRenewInterval {
+		session.Renew(now, s.sessionTTL(), s.sessionIdleTimeout())
+		if err = s.repo.RenewSession(token, session.LastSeen, session.ExpTime); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	user, err := s.repo.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, session, nil
+}
+
+// GetSessions lists the user's active sessions, flagging the one identified
+// by currentToken.
+func (s *service) GetSessions(userID int, currentToken string) ([]models.Session, error) {
+	sessions, err := s.repo.GetSessionsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := sessions[:0]
+	for _, session := range sessions {
+		if session.Expired(now) {
+			continue
+		}
+		session.Current = session.Token == currentToken
+		active = append(active, session)
+	}
+	return active, nil
+}
+
+func (s *service) RevokeSession(userID, sessionID int) error {
+	return s.repo.DeleteSessionByID(userID, sessionID)
+}
+
+func (s *service) RevokeAllSessions(userID int) error {
+	return s.repo.DeleteSessionByUserID(userID)
+}
+
+// RunSessionSweeper deletes expired sessions every interval until ctx is
+// cancelled; a non-positive interval disables it. Sessions are also checked
+// on use, so the sweeper only keeps the table from growing.
+func (s *service) RunSessionSweeper(ctx context.Context, interval time.Duration, errLog *log.Logger) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.repo.DeleteExpiredSessions(time.Now()); err != nil {
+				errLog.Printf("session sweeper: %v", err)
+			}
+		}
+	}
+}
+
+func (s *service) sessionTTL() time.Duration {
+	if s.cfg == nil || s.cfg.SessionTTL <= 0 {
+		return defaultSessionTTL
+	}
+	return s.cfg.SessionTTL
+}
+
+func (s *service) sessionIdleTimeout() time.Duration {
+	if s.cfg == nil || s.cfg.SessionIdleTimeout < 0 {
+		return 0
+	}
+	return s.cfg.SessionIdleTimeout
+}
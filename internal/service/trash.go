@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"forum/models"
+	"log"
+	"time"
+)
+
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// DeletePost moves a post to the trash on behalf of user, who must be its
+// author or a moderator; anyone else gets models.ErrForbidden.
+func (s *service) DeletePost(user *models.User, postID int) error {
+	post, err := s.repo.GetPostByID(postID)
+	if err != nil {
+		return err
+	}
+	if !post.EditableBy(user) {
+		return models.ErrForbidden
+	}
+	return s.repo.DeletePost(postID, time.Now())
+}
+
+func (s *service) RestorePost(postID int) error {
+	return s.repo.RestorePost(postID)
+}
+
+func (s *service) GetDeletedPosts() ([]models.Post, error) {
+	return s.repo.GetDeletedPosts()
+}
+
+func (s *service) PurgePost(postID int) error {
+	return s.repo.PurgePost(postID)
+}
+
+// RunTrashPurger permanently removes posts that have been in the trash for
+// longer than the retention window, every interval until ctx is cancelled;
+// a non-positive interval disables it.
+func (s *service) RunTrashPurger(ctx context.Context, interval time.Duration, errLog *log.Logger) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.repo.PurgeDeletedPosts(time.Now().Add(-s.trashRetention())); err != nil {
+				errLog.Printf("trash purger: %v", err)
+			}
+		}
+	}
+}
+
+func (s *service) trashRetention() time.Duration {
+	if s.cfg == nil || s.cfg.TrashRetention <= 0 {
+		return defaultTrashRetention
+	}
+	return s.cfg.TrashRetention
+}
@@ -1,32 +1,30 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"forum/models"
-	"net/smtp"
+	"forum/pkg/token"
+	"net/url"
+	"time"
 )
 
-func (s *service) GetUser(id int) *models.User {
-	return nil
-}
+const (
+	activationTokenTTL       = 48 * time.Hour
+	activationResendCooldown = 5 * time.Minute
+)
 
-func (s *service) DeleteSession(token string) error {
-	if err := s.repo.DeleteSessionByToken(token); err != nil {
-		return err
-	}
+func (s *service) GetUser(id int) *models.User {
 	return nil
 }
 
-func (s *service) Authenticate(email string, password string) (*models.Session, error) {
+func (s *service) Authenticate(email, password, userAgent string) (*models.Session, error) {
 	userID, err := s.repo.Authenticate(email, password)
 	if err != nil {
 		return nil, err
 	}
-	session := models.NewSession(userID)
-
-	if err = s.repo.DeleteSessionByUserID(userID); err != nil {
-		return nil, err
-	}
+	session := models.NewSession(userID, s.sessionTTL(), s.sessionIdleTimeout())
+	session.UserAgent = truncate(userAgent, maxUserAgentLength)
 
 	if err = s.repo.CreateSession(session); err != nil {
 		return nil, err
@@ -35,36 +33,30 @@ func (s *service) Authenticate(email string, password string) (*models.Session,
 	return session, nil
 }
 func (s *service) CreateUser(user *models.User) error {
-	err := s.repo.CreateUser(user) // Разыменование указателя
-
+	secret, err := token.New()
 	if err != nil {
 		return err
 	}
+	now := time.Now()
+	user.ActivationTokenHash = token.Hash(secret)
+	user.ActivationExpires = now.Add(activationTokenTTL)
+	user.ActivationSent = now
 
-	err = sendActivationEmail(user.Email, user.ActivationToken)
-	if err != nil {
-		return err // Обработка ошибки отправки email
-	}
-	return nil
-}
-
-func (s *service) GetUserByToken(token string) (*models.User, error) {
-	userID, err := s.repo.GetUserIDByToken(token)
+	err = s.repo.CreateUser(user) // Разыменование указателя
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return s.repo.GetUserByID(userID)
+	return s.sendActivationEmail(user.Email, secret)
 }
 
-func (s *service) UpdateUserPassword(token string, newPassword string) error {
-
-	userID, err := s.repo.GetUserIDByToken(token)
-	if err != nil {
+// UpdateUserPassword changes the password after checking the current one,
+// reporting a wrong current password as models.ErrInvalidCredentials.
+func (s *service) UpdateUserPassword(userID int, currentPassword, newPassword string) error {
+	if err := s.repo.CheckUserPassword(userID, currentPassword); err != nil {
 		return err
 	}
-
 	return s.repo.UpdateUserPassword(userID, newPassword)
 }
 func (s *service) GetAllUsers() ([]models.User, error) {
@@ -81,44 +73,121 @@ func (s *service) GetAllUsers() ([]models.User, error) {
 	return users, nil
 }
 
-func (s *service) DeleteUser(userID int) error {
+// DeleteUser deletes an account on behalf of admin. Neither the deleted
+// user placeholder nor admin's own account can be deleted, and the repository
+// refuses to delete the last admin with models.ErrLastAdmin.
+func (s *service) DeleteUser(admin *models.User, userID int) error {
+	if userID == models.DeletedUserID || userID == admin.ID {
+		return models.ErrForbidden
+	}
 	return s.repo.DeleteUser(userID)
 }
 
-const (
-	smtpServer   = "smtp.mail.ru"
-	smtpPort     = "587"
-	smtpUser     = "nurkhat.sergaziev@mail.ru"
-	smtpPassword = "itFCzPK0wcRVDxzTeDaD"
-)
-
-func sendActivationEmail(email, token string) error {
-	from := smtpUser
-	to := []string{email}
-	url := "http://localhost:8080/activate?token=" + token
-	body := fmt.Sprintf("To activate your account, please click on the following link: %s", url)
-	msg := "From: " + from + "\n" +
-		"To: " + email + "\n" +
-		"Subject: Activate Your Account\n\n" +
-		body
-
-	auth := smtp.PlainAuth("", smtpUser, smtpPassword, smtpServer)
-	err := smtp.SendMail(smtpServer+":"+smtpPort, auth, from, to, []byte(msg))
+// PreviewUserDeletion returns the user and what deleting them would affect.
+func (s *service) PreviewUserDeletion(userID int) (*models.User, *models.UserFootprint, error) {
+	if userID == models.DeletedUserID {
+		return nil, nil, models.ErrNoRecord
+	}
+	user, err := s.repo.GetUserByID(userID)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return nil
+	footprint, err := s.repo.GetUserFootprint(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, footprint, nil
+}
+
+func (s *service) sendActivationEmail(email, token string) error {
+	link := s.absoluteURL("/activate", url.Values{"token": {token}})
+	body := fmt.Sprintf("To activate your account, please click on the following link: %s", link)
+	return s.queueMail(email, "Activate Your Account", body)
 }
 
-func (s *service) ActivateUser(token string) error {
-	user, err := s.repo.GetUserByActivationToken(token)
+// ActivateUser activates the account the token was sent to. Unknown and
+// used tokens report models.ErrInvalidToken, stale ones models.ErrTokenExpired.
+func (s *service) ActivateUser(secret string) error {
+	user, err := s.userByActivationToken(secret)
 	if err != nil {
-		return err // Пользователь не найден или другая ошибка
+		return err
 	}
 
+	// Activated accounts only hold a token while changing their email.
 	if user.IsActivated {
-		return fmt.Errorf("user already activated")
+		return models.ErrInvalidToken
 	}
 
 	return s.repo.ActivateUser(user.ID)
 }
+
+// ResendActivation issues a fresh activation link to an account that has not
+// been activated yet. Requests within activationResendCooldown of the last
+// link report models.ErrRateLimited; unknown and activated addresses are
+// ignored so that the form cannot be used to find out who is registered.
+func (s *service) ResendActivation(email string) error {
+	user, err := s.repo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+	if user.IsActivated {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(user.ActivationSent) < activationResendCooldown {
+		return models.ErrRateLimited
+	}
+
+	secret, err := token.New()
+	if err != nil {
+		return err
+	}
+	if err = s.repo.RenewActivationToken(user.ID, token.Hash(secret), now.Add(activationTokenTTL), now); err != nil {
+		return err
+	}
+	return s.sendActivationEmail(user.Email, secret)
+}
+
+// userByActivationToken looks up the owner of an activation token, checking
+// that it is known and has not expired.
+func (s *service) userByActivationToken(secret string) (*models.User, error) {
+	if secret == "" {
+		return nil, models.ErrInvalidToken
+	}
+
+	user, err := s.repo.GetUserByActivationToken(token.Hash(secret))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, models.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !time.Now().Before(user.ActivationExpires) {
+		return nil, models.ErrTokenExpired
+	}
+	return user, nil
+}
+
+// UpdateUserRole changes a user's role. The repository refuses to demote
+// the last admin, in the same transaction as the update, so the dashboard
+// can never lock everyone out.
+func (s *service) UpdateUserRole(userID int, role models.Role) error {
+	return s.repo.UpdateUserRole(userID, role)
+}
+
+// EnsureAdmin grants the admin role to the account registered with email, so
+// that a fresh deployment can bootstrap its first administrator.
+func (s *service) EnsureAdmin(email string) error {
+	user, err := s.repo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user.IsAdmin() {
+		return nil
+	}
+	return s.repo.UpdateUserRole(user.ID, models.RoleAdmin)
+}
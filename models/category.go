@@ -0,0 +1,52 @@
+package models
+
+import (
+	"forum/pkg/validator"
+	"strings"
+	"unicode"
+)
+
+type Category struct {
+	ID          int
+	Name        string
+	Slug        string
+	Description string
+	Position    int
+	Archived    bool
+}
+
+type CategoryForm struct {
+	ID                  int    `form:"id"`
+	Name                string `form:"name"`
+	Slug                string `form:"slug"`
+	Description         string `form:"description"`
+	Position            int    `form:"position"`
+	validator.Validator `form:"-"`
+}
+
+func (f CategoryForm) ToCategory() *Category {
+	return &Category{
+		ID:          f.ID,
+		Name:        f.Name,
+		Slug:        f.Slug,
+		Description: f.Description,
+		Position:    f.Position,
+	}
+}
+
+// Slugify turns a category name into a lowercase, dash-separated identifier
+// suitable for URLs.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
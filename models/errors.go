@@ -10,4 +10,26 @@ var (
 	ErrDuplicateEmail = errors.New("models: duplicate email")
 
 	ErrNotActivated = errors.New("models: user not activated")
+
+	ErrInvalidReaction = errors.New("models: invalid reaction")
+
+	ErrInvalidCategory = errors.New("models: invalid category")
+
+	ErrDuplicateCategory = errors.New("models: duplicate category slug")
+
+	ErrInvalidRole = errors.New("models: invalid role")
+
+	ErrLastAdmin = errors.New("models: cannot remove the last admin")
+
+	ErrInvalidToken = errors.New("models: invalid or expired token")
+
+	ErrTokenExpired = errors.New("models: token expired")
+
+	ErrRateLimited = errors.New("models: too many requests")
+
+	ErrForbidden = errors.New("models: operation not permitted")
+
+	ErrImageTooLarge = errors.New("models: image too large")
+
+	ErrInvalidImage = errors.New("models: unsupported image")
 )
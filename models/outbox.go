@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSent    OutboxStatus = "sent"
+	OutboxFailed  OutboxStatus = "failed"
+)
+
+// OutboxMessage is an email waiting in the outbox table for delivery.
+type OutboxMessage struct {
+	ID          int
+	Recipient   string
+	Subject     string
+	Body        string
+	Status      OutboxStatus
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+	Created     time.Time
+}
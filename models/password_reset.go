@@ -0,0 +1,34 @@
+package models
+
+import (
+	"forum/pkg/validator"
+	"time"
+)
+
+// PasswordReset is an emailed one-time link for choosing a new password.
+// Only the hash of its token is stored.
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	Created   time.Time
+	Expires   time.Time
+	Used      bool
+}
+
+// Usable reports whether the link can still be redeemed at t.
+func (p *PasswordReset) Usable(t time.Time) bool {
+	return !p.Used && t.Before(p.Expires)
+}
+
+type PasswordForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type PasswordResetForm struct {
+	Token                   string `form:"token"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
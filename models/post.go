@@ -1,33 +1,155 @@
 package models
 
 import (
+	"forum/pkg/markdown"
+	"forum/pkg/media"
 	"forum/pkg/validator"
+	"html/template"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Post struct {
-	PostID     int
-	UserID     int
-	UserName   string
-	Title      string
-	Content    string
-	ImageName  string
-	Created    time.Time
+	PostID   int
+	UserID   int
+	UserName string
+	Title    string
+	Content  string
+	// ContentHTML is Content rendered from Markdown, empty for posts stored
+	// before rendering was introduced.
+	ContentHTML string
+	// ImageName is the media name of an uploaded image or the URL of a
+	// remote one; it is empty, or "Nan" on older rows, for posts without one.
+	ImageName string
+	Created   time.Time
+	// Updated is the time of the last edit, zero for posts never edited.
+	Updated time.Time
+	// DeletedAt is set while the post is in the trash.
+	DeletedAt  time.Time
 	Like       int
 	Dislike    int
-	Comment    *[]Comment
-	Categories map[int]string
+	Comments   []Comment
+	Categories []Category
+	// Reaction is the current viewer's reaction to the post.
+	Reaction Reaction
+}
+
+// ImageURL returns the address of the post's image, or "" if it has none.
+func (p *Post) ImageURL() string {
+	switch {
+	case p.ImageName == "" || p.ImageName == "Nan":
+		return ""
+	case isRemoteImage(p.ImageName):
+		return p.ImageName
+	}
+	return "/media/" + p.ImageName
+}
+
+// ThumbnailURL returns the address of a small version of the post's image,
+// or "" if it has none. Remote images have no thumbnail of their own.
+func (p *Post) ThumbnailURL() string {
+	if p.ImageName == "" || p.ImageName == "Nan" || isRemoteImage(p.ImageName) {
+		return p.ImageURL()
+	}
+	return "/media/" + media.ThumbnailName(p.ImageName)
+}
+
+func isRemoteImage(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// HTML returns the post's body as HTML, rendering it for posts stored
+// before bodies were rendered on write.
+func (p *Post) HTML() template.HTML {
+	return renderedHTML(p.Content, p.ContentHTML)
+}
+
+// Edited reports whether the post was changed after it was published.
+func (p *Post) Edited() bool {
+	return !p.Updated.IsZero()
+}
+
+// EditableBy reports whether u may edit the post: its author and moderators
+// can, anonymous visitors cannot.
+func (p *Post) EditableBy(u *User) bool {
+	if u == nil {
+		return false
+	}
+	return u.ID == p.UserID || u.IsModerator()
+}
+
+type Reaction int
+
+const (
+	ReactionNone    Reaction = 0
+	ReactionLike    Reaction = 1
+	ReactionDislike Reaction = -1
+)
+
+func NewReaction(isLike bool) Reaction {
+	if isLike {
+		return ReactionLike
+	}
+	return ReactionDislike
+}
+
+// ParseReaction converts the "reaction" form value into a Reaction.
+func ParseReaction(s string) (Reaction, error) {
+	switch s {
+	case "like":
+		return ReactionLike, nil
+	case "dislike":
+		return ReactionDislike, nil
+	}
+	return ReactionNone, ErrInvalidReaction
+}
+
+func (r Reaction) IsLike() bool {
+	return r == ReactionLike
+}
+
+func (r Reaction) IsDislike() bool {
+	return r == ReactionDislike
 }
 
 type Comment struct {
-	CommentId      int
-	PostID         int
-	CreatedUserID  int
-	Content        string
-	CreatedTime    time.Time
-	LikeCounter    string
-	DislikeCounter string
+	CommentID int
+	PostID    int
+	ParentID  int
+	UserID    int
+	UserName  string
+	Content   string
+	// ContentHTML is Content rendered from Markdown, see Post.ContentHTML.
+	ContentHTML string
+	Created     time.Time
+	Like        int
+	Dislike     int
+	// Depth is the nesting level relative to the root of the fetched thread.
+	Depth int
+	// HasHiddenReplies is set when replies below this comment were cut off
+	// by the maximum nesting depth.
+	HasHiddenReplies bool
+	// Reaction is the current viewer's reaction to the comment.
+	Reaction Reaction
+}
+
+// HTML returns the comment's body as HTML, see Post.HTML.
+func (c *Comment) HTML() template.HTML {
+	return renderedHTML(c.Content, c.ContentHTML)
+}
+
+func renderedHTML(source, rendered string) template.HTML {
+	if rendered == "" {
+		rendered = markdown.Render(source)
+	}
+	return template.HTML(rendered)
+}
+
+type CommentForm struct {
+	Content             string `form:"content"`
+	ParentID            int    `form:"parent_id"`
+	validator.Validator `form:"-"`
 }
 
 type PostForm struct {
@@ -38,6 +160,26 @@ type PostForm struct {
 	validator.Validator `form:"-"`
 }
 
+// CategoryIDs returns the ids of categories, for filling in PostForm when a
+// post is edited.
+func CategoryIDs(categories []Category) []int {
+	ids := make([]int, len(categories))
+	for i, c := range categories {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// Selected reports whether the category with the given id was ticked.
+func (f PostForm) Selected(id int) bool {
+	for _, categoryID := range f.Categories {
+		if categoryID == id {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *PostForm) ConverCategories() error {
 	for _, str := range f.CategoriesString {
 		nb, err := strconv.Atoi(str)
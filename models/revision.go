@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PostRevision is a snapshot of a post taken when it was created or edited.
+type PostRevision struct {
+	ID         int
+	PostID     int
+	EditorID   int
+	EditorName string
+	Title      string
+	Content    string
+	// Categories holds the names of the post's categories at the time,
+	// separated by ", ".
+	Categories string
+	Created    time.Time
+}
+
+// Text renders the revision as the lines compared by the revision diff.
+func (r PostRevision) Text() string {
+	return "Title: " + r.Title + "\nCategories: " + r.Categories + "\n\n" + r.Content
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snippet highlights are delimited by these control characters, so the
+// snippet can be escaped before they are turned into <mark> elements. The
+// service removes them from submitted text with StripSnippetMarks.
+const (
+	SnippetMarkStart = "\x02"
+	SnippetMarkEnd   = "\x03"
+)
+
+var snippetMarkStripper = strings.NewReplacer(SnippetMarkStart, "", SnippetMarkEnd, "")
+
+// StripSnippetMarks removes the snippet delimiters from text.
+func StripSnippetMarks(text string) string {
+	return snippetMarkStripper.Replace(text)
+}
+
+// SearchQuery is a full-text search over posts and their comments. Zero
+// filters are ignored; To is inclusive.
+type SearchQuery struct {
+	Text       string
+	CategoryID int
+	Author     string
+	From       time.Time
+	To         time.Time
+	Page       int
+	PageSize   int
+}
+
+// Offset returns the number of results before the current page.
+func (q SearchQuery) Offset() int {
+	return (q.Page - 1) * q.PageSize
+}
+
+// PageURL returns the search URL for the given page, keeping the filters.
+func (q SearchQuery) PageURL(page int) string {
+	v := url.Values{}
+	v.Set("q", q.Text)
+	if q.CategoryID > 0 {
+		v.Set("category", strconv.Itoa(q.CategoryID))
+	}
+	if q.Author != "" {
+		v.Set("author", q.Author)
+	}
+	if !q.From.IsZero() {
+		v.Set("from", q.FromDate())
+	}
+	if !q.To.IsZero() {
+		v.Set("to", q.ToDate())
+	}
+	if page > 1 {
+		v.Set("page", strconv.Itoa(page))
+	}
+	return "/search?" + v.Encode()
+}
+
+// DateLayout is the format of the date filters, as sent by <input type=date>.
+const DateLayout = "2006-01-02"
+
+// FromDate and ToDate format the date filters for a date input.
+func (q SearchQuery) FromDate() string { return formatDate(q.From) }
+func (q SearchQuery) ToDate() string   { return formatDate(q.To) }
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(DateLayout)
+}
+
+// SearchResult is a post matching a search, with the matching excerpt of
+// its title, body or one of its comments.
+type SearchResult struct {
+	Post
+	Snippet string
+}
+
+// SnippetHTML escapes the snippet and wraps its highlighted terms in <mark>.
+// Delimiters that open or close nothing, which text stored before they were
+// stripped may hold, are dropped, so the elements are always balanced.
+func (r SearchResult) SnippetHTML() template.HTML {
+	s := template.HTMLEscapeString(r.Snippet)
+	var b strings.Builder
+	marked := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == SnippetMarkStart[0] && !marked:
+			b.WriteString("<mark>")
+			marked = true
+		case s[i] == SnippetMarkEnd[0] && marked:
+			b.WriteString("</mark>")
+			marked = false
+		case s[i] == SnippetMarkStart[0] || s[i] == SnippetMarkEnd[0]:
+			// A stray delimiter is dropped.
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	if marked {
+		b.WriteString("</mark>")
+	}
+	return template.HTML(b.String())
+}
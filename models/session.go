@@ -7,15 +7,48 @@ import (
 )
 
 type Session struct {
-	UserID  int
-	Token   string
-	ExpTime time.Time
+	ID        int
+	UserID    int
+	Token     string
+	Created   time.Time
+	LastSeen  time.Time
+	ExpTime   time.Time
+	UserAgent string
+	Current   bool
 }
 
-func NewSession(UserID int) *Session {
-	return &Session{
-		UserID:  UserID,
-		Token:   uuid.New().String(),
-		ExpTime: time.Now().Add(1 * time.Minute),
+// NewSession starts a session that lives for ttl unless idleTimeout is
+// shorter; see Session.Renew.
+func NewSession(UserID int, ttl, idleTimeout time.Duration) *Session {
+	now := time.Now()
+	session := &Session{
+		UserID:   UserID,
+		Token:    uuid.New().String(),
+		Created:  now,
+		LastSeen: now,
 	}
+	session.ExpTime = session.expiry(now, ttl, idleTimeout)
+	return session
+}
+
+// Expired reports whether the session can no longer be used at t.
+func (s *Session) Expired(t time.Time) bool {
+	return !t.Before(s.ExpTime)
+}
+
+// Renew records activity at t and slides the expiry forward by idleTimeout,
+// never past the absolute lifetime ttl counted from creation.
+func (s *Session) Renew(t time.Time, ttl, idleTimeout time.Duration) {
+	s.LastSeen = t
+	s.ExpTime = s.expiry(t, ttl, idleTimeout)
+}
+
+func (s *Session) expiry(t time.Time, ttl, idleTimeout time.Duration) time.Time {
+	exp := s.Created.Add(ttl)
+	if idleTimeout > 0 {
+		if idle := t.Add(idleTimeout); idle.Before(exp) {
+			exp = idle
+		}
+	}
+	return exp
 }
@@ -1,16 +1,35 @@
 package models
 
+import (
+	"forum/pkg/cookie"
+	"forum/pkg/diff"
+	"html/template"
+)
+
 type TemplateData struct {
 	CurrentYear     int
 	Post            *Post
 	Posts           *[]Post
-	Categories      []string
+	Categories      []Category
+	Category        *Category
 	Form            any
-	Flash           string
+	Flashes         []cookie.Flash
 	IsAuthenticated bool
 	CSRFToken       string
 	User            *User
+	CurrentUser     *User
+	Roles           []Role
 	NumberOfPage    int
 	CurrentPage     int
 	Users           []User
+	Footprint       *UserFootprint
+	Sessions        []Session
+	ThreadID        int
+	Revisions       []PostRevision
+	FromRevision    *PostRevision
+	ToRevision      *PostRevision
+	Diff            []diff.Line
+	Preview         template.HTML
+	Search          *SearchQuery
+	SearchResults   []SearchResult
 }
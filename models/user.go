@@ -8,19 +8,81 @@ import (
 )
 
 type User struct {
-	ID              int
-	Name            string
-	Email           string
-	HashedPassword  []byte
-	Created         time.Time
-	Status          int
-	IsActivated     bool
-	ActivationToken string
+	ID             int
+	Name           string
+	Email          string
+	PendingEmail   string
+	HashedPassword []byte
+	Created        time.Time
+	Role           Role
+	IsActivated    bool
+	// ActivationTokenHash is the hash of the token in the latest activation
+	// or email confirmation link; see package token.
+	ActivationTokenHash string
+	ActivationExpires   time.Time
+	ActivationSent      time.Time
+}
+
+// DeletedUserID is the placeholder account that keeps the posts, comments
+// and revisions of deleted users. It cannot sign in.
+const DeletedUserID = 0
+
+// UserFootprint counts what deleting a user affects: their posts and
+// comments pass to the placeholder, while their reactions and sessions are
+// removed.
+type UserFootprint struct {
+	Posts     int
+	Comments  int
+	Reactions int
+	Sessions  int
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role.AtLeast(RoleAdmin)
+}
+
+func (u *User) IsModerator() bool {
+	return u.Role.AtLeast(RoleModerator)
+}
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Roles lists every role from the least to the most privileged.
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
+func ParseRole(s string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == s {
+			return role, nil
+		}
+	}
+	return "", ErrInvalidRole
+}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// AtLeast reports whether r grants every permission of min.
+func (r Role) AtLeast(min Role) bool {
+	return r.rank() >= min.rank() && r.rank() >= 0
 }
 
 type UserLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	Next                string `form:"next"`
 	validator.Validator `form:"-"`
 }
 
@@ -40,6 +102,18 @@ func (u UserSignupForm) FormToUser() User {
 	}
 }
 
+type ActivationResendForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type AccountSettingsForm struct {
+	Name                string `form:"name"`
+	Email               string `form:"email"`
+	CurrentPassword     string `form:"currentPassword"`
+	validator.Validator `form:"-"`
+}
+
 type UserPasswordUpdateForm struct {
 	CurrentPassword         string `form:"currentPassword"`
 	NewPassword             string `form:"newPassword"`
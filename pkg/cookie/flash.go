@@ -0,0 +1,157 @@
+package cookie
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	flashCookieName = "flash"
+	// flashMaxAge keeps a flash that is never shown from lingering.
+	flashMaxAge = 5 * 60
+)
+
+type FlashLevel string
+
+const (
+	FlashInfo    FlashLevel = "info"
+	FlashSuccess FlashLevel = "success"
+	FlashError   FlashLevel = "error"
+)
+
+// Flash is a one-shot message shown on the next page the user sees.
+type Flash struct {
+	Level   FlashLevel `json:"level"`
+	Message string     `json:"message"`
+}
+
+// FlashStore keeps flashes in a short-lived cookie signed with HMAC-SHA256,
+// so they survive redirects for signed-in and anonymous visitors alike.
+type FlashStore struct {
+	key []byte
+}
+
+// NewFlashStore returns a store signing with key. Without a key a random one
+// is used, which only means that flashes pending at a restart are dropped.
+func NewFlashStore(key []byte) *FlashStore {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &FlashStore{key: key}
+}
+
+type flashContextKey struct{}
+
+type flashState struct {
+	w        http.ResponseWriter
+	incoming []Flash
+	outgoing []Flash
+}
+
+func (st *flashState) pending() []Flash {
+	flashes := make([]Flash, 0, len(st.incoming)+len(st.outgoing))
+	flashes = append(flashes, st.incoming...)
+	return append(flashes, st.outgoing...)
+}
+
+// Load reads the flashes sent with the request, so that Put and Pop can be
+// used by the handlers behind it.
+func (s *FlashStore) Load(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &flashState{w: w, incoming: s.read(r)}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), flashContextKey{}, state)))
+	})
+}
+
+// Put queues a flash for the next page rendered for this visitor, which may
+// be the current one.
+func (s *FlashStore) Put(r *http.Request, level FlashLevel, message string) {
+	state, ok := r.Context().Value(flashContextKey{}).(*flashState)
+	if !ok {
+		return
+	}
+	state.outgoing = append(state.outgoing, Flash{Level: level, Message: message})
+	s.write(state.w, state.pending())
+}
+
+// Pop returns every pending flash and clears them.
+func (s *FlashStore) Pop(r *http.Request) []Flash {
+	state, ok := r.Context().Value(flashContextKey{}).(*flashState)
+	if !ok {
+		return nil
+	}
+	flashes := state.pending()
+	if len(flashes) > 0 {
+		state.incoming, state.outgoing = nil, nil
+		s.write(state.w, nil)
+	}
+	return flashes
+}
+
+func (s *FlashStore) read(r *http.Request) []Flash {
+	c, err := r.Cookie(flashCookieName)
+	if err != nil {
+		return nil
+	}
+
+	payload, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil
+	}
+	var flashes []Flash
+	if err := json.Unmarshal(raw, &flashes); err != nil {
+		return nil
+	}
+	return flashes
+}
+
+// write replaces any flash cookie already set on w, so that several Puts in
+// one request end up as a single cookie.
+func (s *FlashStore) write(w http.ResponseWriter, flashes []Flash) {
+	header := w.Header()
+	cookies := header.Values("Set-Cookie")
+	header.Del("Set-Cookie")
+	for _, v := range cookies {
+		if !strings.HasPrefix(v, flashCookieName+"=") {
+			header.Add("Set-Cookie", v)
+		}
+	}
+
+	c := &http.Cookie{
+		Name:     flashCookieName,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if len(flashes) == 0 {
+		c.MaxAge = -1
+	} else {
+		raw, err := json.Marshal(flashes)
+		if err != nil {
+			return
+		}
+		payload := base64.RawURLEncoding.EncodeToString(raw)
+		c.Value = payload + "." + s.sign(payload)
+		c.MaxAge = flashMaxAge
+	}
+	http.SetCookie(w, c)
+}
+
+func (s *FlashStore) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,117 @@
+// Package csrf protects state-changing requests with the double-submit cookie
+// pattern: every visitor gets a random token in a cookie, and unsafe requests
+// must echo it back in a form field or header.
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+)
+
+const (
+	cookieName = "csrf_token"
+	// FieldName is the form field forms put the token in.
+	FieldName = "csrf_token"
+	// HeaderName is the header scripts put the token in.
+	HeaderName = "X-CSRF-Token"
+
+	tokenLength = 32
+	cookieTTL   = 365 * 24 * time.Hour
+)
+
+type contextKey string
+
+const tokenContextKey = contextKey("csrf_token")
+
+// Token returns the token for the request, as set by Protect. It is empty for
+// requests that did not pass through Protect.
+func Token(r *http.Request) string {
+	token, _ := r.Context().Value(tokenContextKey).(string)
+	return token
+}
+
+// Protect issues a token cookie to visitors that have none and rejects
+// POST, PUT, PATCH and DELETE requests whose submitted token does not match
+// the cookie by calling failure instead of next.
+//
+// The token is read from the header named HeaderName or, failing that, from
+// the field named FieldName of a form the caller has already parsed. Protect
+// never reads the body itself, so the caller decides how much of it to keep
+// in memory.
+func Protect(next, failure http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if c, err := r.Cookie(cookieName); err == nil && validToken(c.Value) {
+			token = c.Value
+		}
+
+		if !safeMethod(r.Method) {
+			if token == "" || !equal(token, submittedToken(r)) {
+				failure.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if token == "" {
+			var err error
+			token, err = newToken()
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			setCookie(w, token)
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey, token)))
+	})
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func submittedToken(r *http.Request) string {
+	if token := r.Header.Get(HeaderName); token != "" {
+		return token
+	}
+	if r.PostForm == nil {
+		return ""
+	}
+	return r.PostForm.Get(FieldName)
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func validToken(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(b) == tokenLength
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func setCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(cookieTTL),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
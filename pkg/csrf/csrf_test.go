@@ -0,0 +1,237 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// protect serves req through Protect, reporting the response, whether the
+// request reached next and the token next saw.
+func protect(t *testing.T, req *http.Request) (*http.Response, bool, string) {
+	t.Helper()
+	var passed bool
+	var token string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed = true
+		token = Token(r)
+	})
+	failure := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	rec := httptest.NewRecorder()
+	Protect(next, failure).ServeHTTP(rec, req)
+	return rec.Result(), passed, token
+}
+
+func issuedToken(resp *http.Response) string {
+	for _, c := range resp.Cookies() {
+		if c.Name == cookieName {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestProtectIssuesToken(t *testing.T) {
+	valid, err := newToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		cookie    string
+		wantFresh bool
+	}{
+		{
+			name:      "Missing cookie",
+			wantFresh: true,
+		},
+		{
+			name:      "Malformed cookie",
+			cookie:    "not-a-token",
+			wantFresh: true,
+		},
+		{
+			name:      "Short cookie",
+			cookie:    valid[:len(valid)-4],
+			wantFresh: true,
+		},
+		{
+			name:   "Valid cookie",
+			cookie: valid,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: cookieName, Value: tc.cookie})
+			}
+
+			resp, passed, token := protect(t, req)
+			if !passed {
+				t.Fatalf("got %d; want the request passed on", resp.StatusCode)
+			}
+			issued := issuedToken(resp)
+			if !tc.wantFresh {
+				if issued != "" || token != tc.cookie {
+					t.Errorf("got cookie %q and token %q; want the cookie's token kept", issued, token)
+				}
+				return
+			}
+			if !validToken(issued) || issued == tc.cookie || token != issued {
+				t.Errorf("got cookie %q and token %q; want a fresh token in both", issued, token)
+			}
+		})
+	}
+}
+
+func TestProtectChecksToken(t *testing.T) {
+	token, err := newToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := newToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		cookie     string
+		header     string
+		field      string
+		query      string
+		parseForm  bool
+		wantPassed bool
+	}{
+		{
+			name:       "Safe method without token",
+			method:     http.MethodGet,
+			wantPassed: true,
+		},
+		{
+			name:       "Head without token",
+			method:     http.MethodHead,
+			wantPassed: true,
+		},
+		{
+			name:   "Missing cookie",
+			method: http.MethodPost,
+			header: token,
+		},
+		{
+			name:   "Invalid cookie",
+			method: http.MethodPost,
+			cookie: "forged",
+			header: "forged",
+		},
+		{
+			name:   "Missing token",
+			method: http.MethodPost,
+			cookie: token,
+		},
+		{
+			name:   "Mismatched header",
+			method: http.MethodPost,
+			cookie: token,
+			header: other,
+		},
+		{
+			name:       "Header",
+			method:     http.MethodPost,
+			cookie:     token,
+			header:     token,
+			wantPassed: true,
+		},
+		{
+			name:       "Header on delete",
+			method:     http.MethodDelete,
+			cookie:     token,
+			header:     token,
+			wantPassed: true,
+		},
+		{
+			name:   "Put without token",
+			method: http.MethodPut,
+			cookie: token,
+		},
+		{
+			name:       "Form field",
+			method:     http.MethodPost,
+			cookie:     token,
+			field:      token,
+			parseForm:  true,
+			wantPassed: true,
+		},
+		{
+			name:      "Mismatched form field",
+			method:    http.MethodPost,
+			cookie:    token,
+			field:     other,
+			parseForm: true,
+		},
+		{
+			// Protect leaves reading the body to the caller.
+			name:   "Unparsed form field",
+			method: http.MethodPost,
+			cookie: token,
+			field:  token,
+		},
+		{
+			name:      "Token in the query",
+			method:    http.MethodPost,
+			cookie:    token,
+			query:     token,
+			parseForm: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target := "/"
+			if tc.query != "" {
+				target += "?" + FieldName + "=" + url.QueryEscape(tc.query)
+			}
+			form := url.Values{}
+			if tc.field != "" {
+				form.Set(FieldName, tc.field)
+			}
+			req := httptest.NewRequest(tc.method, target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: cookieName, Value: tc.cookie})
+			}
+			if tc.header != "" {
+				req.Header.Set(HeaderName, tc.header)
+			}
+			if tc.parseForm {
+				if err := req.ParseForm(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resp, passed, _ := protect(t, req)
+			if passed != tc.wantPassed {
+				t.Errorf("got %d, passed on: %t; want passed on: %t", resp.StatusCode, passed, tc.wantPassed)
+			}
+			if !passed && resp.StatusCode != http.StatusForbidden {
+				t.Errorf("got %d; want the failure handler's %d", resp.StatusCode, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := Token(req); got != "" {
+		t.Errorf("got %q; want no token outside Protect", got)
+	}
+}
@@ -0,0 +1,118 @@
+// Package diff compares texts line by line.
+package diff
+
+import "strings"
+
+// maxTable bounds the lines of the old text times those of the new one,
+// after their common beginning and end are set aside, for which the longest
+// common subsequence is computed. It keeps large texts, which anyone can
+// compare through a post's history, from using quadratic memory and time.
+const maxTable = 1 << 21
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is one line of a diff: kept, added in the new text or removed from
+// the old one.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+func (l Line) IsInsert() bool { return l.Op == Insert }
+
+func (l Line) IsDelete() bool { return l.Op == Delete }
+
+// Lines returns the shortest edit turning a into b, based on their longest
+// common subsequence of lines. Removed lines come before the lines added in
+// their place. When the lines that differ are too many to compare, they are
+// all shown as removed and added again.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(x)+len(y)-prefix-suffix)
+	for _, l := range x[:prefix] {
+		lines = append(lines, Line{Equal, l})
+	}
+	lines = appendEdit(lines, x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])
+	for _, l := range x[len(x)-suffix:] {
+		lines = append(lines, Line{Equal, l})
+	}
+	return lines
+}
+
+// appendEdit appends the edit turning x into y to lines.
+func appendEdit(lines []Line, x, y []string) []Line {
+	if len(x) > 0 && len(y) > maxTable/len(x) {
+		for _, l := range x {
+			lines = append(lines, Line{Delete, l})
+		}
+		for _, l := range y {
+			lines = append(lines, Line{Insert, l})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, Line{Equal, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Delete, x[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, Line{Insert, y[j]})
+	}
+	return lines
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
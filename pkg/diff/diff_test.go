@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// format writes lines one per entry, prefixed with " ", "+" or "-" like a
+// unified diff.
+func format(lines []Line) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		switch l.Op {
+		case Insert:
+			out[i] = "+" + l.Text
+		case Delete:
+			out[i] = "-" + l.Text
+		default:
+			out[i] = " " + l.Text
+		}
+	}
+	return out
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "Both empty",
+			want: []string{},
+		},
+		{
+			name: "Unchanged",
+			a:    "a\nb",
+			b:    "a\nb",
+			want: []string{" a", " b"},
+		},
+		{
+			name: "Added to empty",
+			b:    "a\nb",
+			want: []string{"+a", "+b"},
+		},
+		{
+			name: "Emptied",
+			a:    "a\nb",
+			want: []string{"-a", "-b"},
+		},
+		{
+			name: "Line replaced",
+			a:    "a\nb\nc",
+			b:    "a\nx\nc",
+			want: []string{" a", "-b", "+x", " c"},
+		},
+		{
+			name: "Lines inserted and removed",
+			a:    "a\nb\nc\nd",
+			b:    "b\nc\ne\nd",
+			want: []string{"-a", " b", " c", "+e", " d"},
+		},
+		{
+			name: "Common lines in the middle",
+			a:    "x\nsame\ny",
+			b:    "p\nsame\nq",
+			want: []string{"-x", "+p", " same", "-y", "+q"},
+		},
+		{
+			name: "Windows line endings",
+			a:    "a\r\nb",
+			b:    "a\nb",
+			want: []string{" a", " b"},
+		},
+		{
+			name: "Trailing newline added",
+			a:    "a",
+			b:    "a\n",
+			want: []string{" a", "+"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := format(Lines(tc.a, tc.b)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLinesTooManyToCompare(t *testing.T) {
+	// Every line differs, and there are more than maxTable pairs of them,
+	// so the change is shown as a whole between the common first and last
+	// lines.
+	n := 2000
+	var a, b []string
+	a = append(a, "first")
+	b = append(b, "first")
+	for i := 0; i < n; i++ {
+		a = append(a, fmt.Sprint("old ", i))
+		b = append(b, fmt.Sprint("new ", i))
+	}
+	a = append(a, "last")
+	b = append(b, "last")
+	if n*n <= maxTable {
+		t.Fatalf("%d lines are few enough to compare", n)
+	}
+
+	got := format(Lines(strings.Join(a, "\n"), strings.Join(b, "\n")))
+	want := []string{" first"}
+	for _, l := range a[1 : n+1] {
+		want = append(want, "-"+l)
+	}
+	for _, l := range b[1 : n+1] {
+		want = append(want, "+"+l)
+	}
+	want = append(want, " last")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d lines starting %q; want %d lines starting %q", len(got), got[:3], len(want), want[:3])
+	}
+}
+
+func TestLinesLongCommonText(t *testing.T) {
+	// The shared beginning and end are set aside before comparing, so a
+	// small change to a long text is still shown line by line.
+	lines := make([]string, 50000)
+	for i := range lines {
+		lines[i] = fmt.Sprint("line ", i)
+	}
+	a := strings.Join(lines, "\n")
+	lines[25000] = "changed"
+	b := strings.Join(lines, "\n")
+
+	var changed []string
+	for _, l := range format(Lines(a, b)) {
+		if l[0] != ' ' {
+			changed = append(changed, l)
+		}
+	}
+	if want := []string{"-line 25000", "+changed"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("got %q; want %q", changed, want)
+	}
+}
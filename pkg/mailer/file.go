@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// File writes every message into the new/ folder of a maildir, so that mail
+// can be read locally without a server.
+type File struct {
+	Dir  string
+	From string
+	seq  atomic.Uint64
+}
+
+func NewFile(dir, from string) (*File, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &File{Dir: dir, From: from}, nil
+}
+
+// Send writes the message to tmp/ first and then renames it into new/, as
+// maildir readers expect.
+func (m *File) Send(msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	name := fmt.Sprintf("%d.%d_%d.%s", time.Now().Unix(), os.Getpid(), m.seq.Add(1), host)
+
+	tmp := filepath.Join(m.Dir, "tmp", name)
+	if err := os.WriteFile(tmp, format(m.From, msg), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.Dir, "new", name))
+}
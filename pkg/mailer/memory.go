@@ -0,0 +1,36 @@
+package mailer
+
+import "sync"
+
+// Memory keeps sent messages in memory, for tests.
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+	// Err, when set, is returned by Send instead of recording the message.
+	Err error
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
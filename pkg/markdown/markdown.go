@@ -0,0 +1,396 @@
+// Package markdown renders the Markdown subset used for posts and comments:
+// paragraphs, headings, emphasis, inline and fenced code, block quotes,
+// lists, rules and links. Raw HTML in the source is shown as text, and the
+// output goes through Sanitize, so nothing outside its allowlist reaches the
+// page.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRX = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	ruleRX    = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	bulletRX  = regexp.MustCompile(`^ {0,3}[-*+][ \t]+`)
+	orderedRX = regexp.MustCompile(`^ {0,3}\d{1,9}[.)][ \t]+`)
+	fenceRX   = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+)
+
+// maxNesting bounds how deeply quotes and lists nest. Deeper markers are
+// shown as text, so that no input can make the renderer recurse without
+// limit.
+const maxNesting = 32
+
+// Render converts Markdown source to sanitized HTML.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), 0)
+	return Sanitize(b.String())
+}
+
+// renderBlocks writes the blocks of lines, which are nested depth quotes or
+// lists deep.
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case fenceRX.MatchString(line):
+			fence := fenceRX.FindStringSubmatch(line)[1]
+			i++
+			var code []string
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // the closing fence, if any
+			b.WriteString("<pre><code>")
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>\n")
+
+		case headingRX.MatchString(trimmed):
+			m := headingRX.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">")
+			renderInline(b, m[2], false)
+			b.WriteString("</h" + level + ">\n")
+			i++
+
+		case ruleRX.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">") && depth < maxNesting:
+			var quote []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quote = append(quote, strings.TrimPrefix(q, " "))
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quote, depth+1)
+			b.WriteString("</blockquote>\n")
+
+		case (bulletRX.MatchString(line) || orderedRX.MatchString(line)) && depth < maxNesting:
+			i = renderList(b, lines, i, depth)
+
+		default:
+			// The first line may start a quote or list nested too deeply to
+			// render; it becomes text.
+			para := []string{line}
+			i++
+			for i < len(lines) && !startsBlock(lines[i]) {
+				para = append(para, lines[i])
+				i++
+			}
+			b.WriteString("<p>")
+			for j, l := range para {
+				if j > 0 {
+					if strings.HasSuffix(para[j-1], "  ") {
+						b.WriteString("<br>")
+					}
+					b.WriteString("\n")
+				}
+				renderInline(b, strings.TrimSpace(l), false)
+			}
+			b.WriteString("</p>\n")
+		}
+	}
+}
+
+// startsBlock reports whether line ends a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || fenceRX.MatchString(line) || headingRX.MatchString(trimmed) ||
+		ruleRX.MatchString(line) || strings.HasPrefix(trimmed, ">") ||
+		bulletRX.MatchString(line) || orderedRX.MatchString(line)
+}
+
+// renderList renders the list starting at lines[i] and returns the index of
+// the first line after it. Lines indented below an item belong to it and may
+// hold nested blocks.
+func renderList(b *strings.Builder, lines []string, i, depth int) int {
+	marker := bulletRX
+	tag := "ul"
+	if !bulletRX.MatchString(lines[i]) {
+		marker, tag = orderedRX, "ol"
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	for i < len(lines) && marker.MatchString(lines[i]) {
+		item := []string{marker.ReplaceAllString(lines[i], "")}
+		i++
+		for i < len(lines) {
+			line := lines[i]
+			indented := strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+			if strings.TrimSpace(line) == "" {
+				// A blank line only continues the item if indented content
+				// follows it.
+				if i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" &&
+					(strings.HasPrefix(lines[i+1], "  ") || strings.HasPrefix(lines[i+1], "\t")) {
+					item = append(item, "")
+					i++
+					continue
+				}
+				break
+			}
+			if !indented && startsBlock(line) {
+				break
+			}
+			item = append(item, dedent(line))
+			i++
+		}
+
+		var inner strings.Builder
+		renderBlocks(&inner, item, depth+1)
+		body := strings.TrimSuffix(inner.String(), "\n")
+		// A single paragraph makes a tight item, shown without <p>.
+		if strings.HasPrefix(body, "<p>") && strings.HasSuffix(body, "</p>") && strings.Count(body, "<p>") == 1 {
+			body = strings.TrimSuffix(strings.TrimPrefix(body, "<p>"), "</p>")
+		}
+		b.WriteString("<li>" + body + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func dedent(line string) string {
+	if strings.HasPrefix(line, "\t") {
+		return line[1:]
+	}
+	for n := 0; n < 4 && strings.HasPrefix(line, " "); n++ {
+		line = line[1:]
+	}
+	return line
+}
+
+// renderInline writes the inline elements of text. Inside a link, inLink
+// keeps further links from being nested.
+func renderInline(b *strings.Builder, text string, inLink bool) {
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			n := runLength(text[i:], '`')
+			delim := text[i : i+n]
+			if end := strings.Index(text[i+n:], delim); end >= 0 {
+				code := strings.TrimSpace(text[i+n : i+n+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n + end + n
+				continue
+			}
+			b.WriteString(delim)
+			i += n
+			continue
+
+		case c == '*' || c == '_':
+			if n, ok := renderEmphasis(b, text, i, inLink); ok {
+				i += n
+				continue
+			}
+
+		case c == '[' && !inLink:
+			if n, ok := renderLink(b, text[i:]); ok {
+				i += n
+				continue
+			}
+
+		case c == '<' && !inLink:
+			if end := strings.IndexByte(text[i:], '>'); end > 0 {
+				url := text[i+1 : i+end]
+				if !strings.ContainsAny(url, " \t") && isAbsoluteURL(url) {
+					writeLink(b, url, html.EscapeString(url))
+					i += end + 1
+					continue
+				}
+			}
+
+		case (c == 'h' || c == 'H') && !inLink && (i == 0 || !isWordByte(text[i-1])):
+			if n := bareURLLength(text[i:]); n > 0 {
+				url := text[i : i+n]
+				writeLink(b, url, html.EscapeString(url))
+				i += n
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+}
+
+// renderEmphasis renders *em*, **strong** and their underscore forms starting
+// at text[i], returning how many bytes were consumed.
+func renderEmphasis(b *strings.Builder, text string, i int, inLink bool) (int, bool) {
+	c := text[i]
+	n := runLength(text[i:], c)
+	if n > 2 {
+		n = 2
+	}
+	delim := text[i : i+n]
+	start := i + n
+	if start >= len(text) || text[start] == ' ' || text[start] == '\t' {
+		return 0, false
+	}
+	// Underscores inside words, as in snake_case, are not emphasis.
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return 0, false
+	}
+
+	for j := start + 1; j+n <= len(text); j++ {
+		if text[j:j+n] != delim || text[j-1] == ' ' || text[j-1] == '\t' {
+			continue
+		}
+		if n == 1 && j+1 < len(text) && text[j+1] == c {
+			// Part of a longer run, such as the end of **strong**.
+			j++
+			continue
+		}
+		if c == '_' && j+n < len(text) && isWordByte(text[j+n]) {
+			continue
+		}
+		tag := "em"
+		if n == 2 {
+			tag = "strong"
+		}
+		b.WriteString("<" + tag + ">")
+		renderInline(b, text[start:j], inLink)
+		b.WriteString("</" + tag + ">")
+		return j + n - i, true
+	}
+	return 0, false
+}
+
+// renderLink renders [text](url "title") at the start of text, returning how
+// many bytes were consumed. Links to unsafe URLs keep only their text.
+func renderLink(b *strings.Builder, text string) (int, bool) {
+	depth := 0
+	closing := -1
+	for j := 0; j < len(text) && closing < 0; j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = j
+			}
+		}
+	}
+	if closing < 0 || closing+1 >= len(text) || text[closing+1] != '(' {
+		return 0, false
+	}
+	// The target ends at the first unbalanced ")", so URLs may contain
+	// parentheses of their own.
+	end, parens := -1, 0
+	for j := closing + 2; j < len(text) && end < 0; j++ {
+		switch text[j] {
+		case '(':
+			parens++
+		case ')':
+			if parens == 0 {
+				end = j - closing - 2
+			}
+			parens--
+		}
+	}
+	if end < 0 {
+		return 0, false
+	}
+	target := strings.TrimSpace(text[closing+2 : closing+2+end])
+	if sp := strings.IndexAny(target, " \t"); sp >= 0 {
+		target = target[:sp] // drop the optional title
+	}
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+
+	var label strings.Builder
+	renderInline(&label, text[1:closing], true)
+	if SafeURL(target) {
+		writeLink(b, target, label.String())
+	} else {
+		b.WriteString(label.String())
+	}
+	return closing + 2 + end + 1, true
+}
+
+func writeLink(b *strings.Builder, url, label string) {
+	b.WriteString(`<a href="` + html.EscapeString(url) + `" rel="nofollow noopener">` + label + "</a>")
+}
+
+// bareURLLength returns the length of the http(s) URL at the start of text,
+// leaving out trailing punctuation, or 0 if there is none.
+func bareURLLength(text string) int {
+	lower := strings.ToLower(text)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return 0
+	}
+	n := strings.IndexAny(text, " \t\n<>\"")
+	if n < 0 {
+		n = len(text)
+	}
+	for n > 0 && strings.IndexByte(".,:;!?)'", text[n-1]) >= 0 {
+		n--
+	}
+	if !isAbsoluteURL(text[:n]) {
+		return 0
+	}
+	return n
+}
+
+func isAbsoluteURL(url string) bool {
+	lower := strings.ToLower(url)
+	return (strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")) && len(url) > len("https://") && SafeURL(url)
+}
+
+// SafeURL reports whether url may be used as a link target: relative URLs
+// and the http, https and mailto schemes are allowed.
+func SafeURL(url string) bool {
+	if url == "" {
+		return false
+	}
+	for _, r := range url {
+		if r < ' ' || r == 0x7f {
+			return false
+		}
+	}
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return true
+	}
+	switch strings.ToLower(url[:colon]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,316 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Paragraphs",
+			src:  "one\ntwo  \nthree\n\nfour",
+			want: "<p>one\ntwo<br>\nthree</p>\n<p>four</p>\n",
+		},
+		{
+			name: "Heading and rule",
+			src:  "## Title ##\n***",
+			want: "<h2>Title</h2>\n<hr>\n",
+		},
+		{
+			name: "Emphasis",
+			src:  "*em* **strong** _em_ snake_case_name",
+			want: "<p><em>em</em> <strong>strong</strong> <em>em</em> snake_case_name</p>\n",
+		},
+		{
+			name: "Code",
+			src:  "`<b>` and\n```\n<script>alert(1)</script>\n```",
+			want: "<p><code>&lt;b&gt;</code> and</p>\n<pre><code>&lt;script&gt;alert(1)&lt;/script&gt;</code></pre>\n",
+		},
+		{
+			name: "Lists and quotes",
+			src:  "- a\n- b\n\n1. c\n\n> quoted",
+			want: "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>c</li>\n</ol>\n<blockquote>\n<p>quoted</p>\n</blockquote>\n",
+		},
+		{
+			name: "Raw HTML",
+			src:  `<script>alert(1)</script><img src=x onerror="alert(1)">`,
+			want: "<p>&lt;script&gt;alert(1)&lt;/script&gt;&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>\n",
+		},
+		{
+			name: "Raw HTML in a heading",
+			src:  `# <a href="javascript:alert(1)">x</a>`,
+			want: "<h1>&lt;a href=&#34;javascript:alert(1)&#34;&gt;x&lt;/a&gt;</h1>\n",
+		},
+		{
+			name: "Unclosed raw tag",
+			src:  "<em>open",
+			want: "<p>&lt;em&gt;open</p>\n",
+		},
+		{
+			name: "Escaped punctuation",
+			src:  `\*not em\* \<b\>`,
+			want: "<p>*not em* &lt;b&gt;</p>\n",
+		},
+		{
+			name: "Link",
+			src:  `[docs](https://example.com/a_(b) "Title")`,
+			want: `<p><a href="https://example.com/a_(b)" rel="nofollow noopener">docs</a></p>` + "\n",
+		},
+		{
+			name: "Relative link",
+			src:  "[post](/post/1)",
+			want: `<p><a href="/post/1" rel="nofollow noopener">post</a></p>` + "\n",
+		},
+		{
+			name: "Link with quotes in its target",
+			src:  `[x](https://example.com/"onmouseover="alert(1))`,
+			want: `<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1)" rel="nofollow noopener">x</a></p>` + "\n",
+		},
+		{
+			name: "JavaScript link",
+			src:  "[click](javascript:alert(1))",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "Mixed-case scheme",
+			src:  "[click](JaVaScRiPt:alert(1))",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "Scheme split by a control character",
+			src:  "[click](java\x0bscript:alert(1))",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "Scheme split by a space",
+			src:  "[click](java script:alert(1))",
+			want: `<p><a href="java" rel="nofollow noopener">click</a></p>` + "\n",
+		},
+		{
+			// Entities are not decoded in link targets, so this is a
+			// relative URL.
+			name: "Entity-encoded scheme",
+			src:  "[click](&#106;avascript:alert(1))",
+			want: `<p><a href="&amp;#106;avascript:alert(1)" rel="nofollow noopener">click</a></p>` + "\n",
+		},
+		{
+			name: "Entity-encoded colon",
+			src:  "[click](javascript&#58;alert(1))",
+			want: `<p><a href="javascript&amp;#58;alert(1)" rel="nofollow noopener">click</a></p>` + "\n",
+		},
+		{
+			name: "Data link",
+			src:  "[click](data:text/html;base64,PHNjcmlwdD4=)",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "Nested links",
+			src:  "[outer [inner](https://b.example)](https://a.example)",
+			want: `<p><a href="https://a.example" rel="nofollow noopener">outer [inner](https://b.example)</a></p>` + "\n",
+		},
+		{
+			name: "Link in emphasis in a link",
+			src:  "[*https://b.example*](https://a.example)",
+			want: `<p><a href="https://a.example" rel="nofollow noopener"><em>https://b.example</em></a></p>` + "\n",
+		},
+		{
+			name: "Autolinks",
+			src:  "<https://example.com> and https://example.com/x.",
+			want: `<p><a href="https://example.com" rel="nofollow noopener">https://example.com</a> and <a href="https://example.com/x" rel="nofollow noopener">https://example.com/x</a>.</p>` + "\n",
+		},
+		{
+			name: "JavaScript autolink",
+			src:  "<javascript:alert(1)>",
+			want: "<p>&lt;javascript:alert(1)&gt;</p>\n",
+		},
+		{
+			name: "Bare URL followed by markup",
+			src:  `https://example.com/"><script>`,
+			want: `<p><a href="https://example.com/" rel="nofollow noopener">https://example.com/</a>&#34;&gt;&lt;script&gt;</p>` + "\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Render(tc.src); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenderDeepNesting(t *testing.T) {
+	const n = 100000
+	tests := []struct {
+		name string
+		src  string
+		tag  string
+	}{
+		{"Quotes", strings.Repeat(">", n) + " x", "blockquote"},
+		{"Lists", strings.Repeat("- ", n) + "x", "ul"},
+		{"Indented lists", nestedList(1000), "ul"},
+		{"Quoted lists", strings.Repeat("> - ", n) + "x", "blockquote"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := nesting(Render(tc.src), tc.tag); got == 0 || got > maxNesting {
+				t.Errorf("got %s nested %d deep; want at most %d", tc.tag, got, maxNesting)
+			}
+		})
+	}
+}
+
+// nestedList returns a list whose every item holds a list indented below it.
+func nestedList(depth int) string {
+	var b strings.Builder
+	for i := 0; i < depth; i++ {
+		b.WriteString(strings.Repeat("  ", i) + "- x\n")
+	}
+	return b.String()
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "Allowed elements",
+			html: "<p>a<br/><em>b</em></p>",
+			want: "<p>a<br><em>b</em></p>",
+		},
+		{
+			name: "Disallowed elements",
+			html: "<script>alert(1)</script><iframe src=x></iframe>",
+			want: "&lt;script&gt;alert(1)&lt;/script&gt;&lt;iframe src=x&gt;&lt;/iframe&gt;",
+		},
+		{
+			name: "Attributes",
+			html: `<p class="x" onclick="alert(1)">a</p>`,
+			want: "<p>a</p>",
+		},
+		{
+			name: "Upper-case tags",
+			html: "<P>a</P><SCRIPT>",
+			want: "<p>a</p>&lt;SCRIPT&gt;",
+		},
+		{
+			name: "Safe link",
+			html: `<a href='https://example.com/?a=1&amp;b=2' title="x">a</a>`,
+			want: `<a href="https://example.com/?a=1&amp;b=2" rel="nofollow noopener">a</a>`,
+		},
+		{
+			name: "Unquoted href",
+			html: `<a href=/post/1>a</a>`,
+			want: `<a href="/post/1" rel="nofollow noopener">a</a>`,
+		},
+		{
+			name: "JavaScript link",
+			html: `<a href="javascript:alert(1)">a</a>`,
+			want: "<a>a</a>",
+		},
+		{
+			name: "Mixed-case scheme",
+			html: `<a href="JaVaScRiPt:alert(1)">a</a>`,
+			want: "<a>a</a>",
+		},
+		{
+			name: "Entity-encoded scheme",
+			html: `<a href="&#106;avascript&#x3A;alert(1)">a</a>`,
+			want: "<a>a</a>",
+		},
+		{
+			name: "Entity-encoded tab in the scheme",
+			html: `<a href="java&#9;script:alert(1)">a</a>`,
+			want: "<a>a</a>",
+		},
+		{
+			name: "Leading space",
+			html: `<a href=" javascript:alert(1)">a</a>`,
+			want: "<a>a</a>",
+		},
+		{
+			name: "Attribute breaking out of quotes",
+			html: `<a href='https://example.com/"onmouseover="alert(1)'>a</a>`,
+			want: `<a href="https://example.com/&#34;onmouseover=&#34;alert(1)" rel="nofollow noopener">a</a>`,
+		},
+		{
+			name: "Unclosed elements",
+			html: "<blockquote><p><strong>a",
+			want: "<blockquote><p><strong>a</strong></p></blockquote>",
+		},
+		{
+			name: "Misnested elements",
+			html: "<em><strong>a</em>b</strong>",
+			want: "<em><strong>a</strong></em>b",
+		},
+		{
+			name: "Unterminated tag",
+			html: `<a href="x`,
+			want: `&lt;a href="x`,
+		},
+		{
+			name: "Stray brackets and ampersands",
+			html: "1 < 2 > 0 & a &amp; b &#39; &bogus",
+			want: "1 &lt; 2 &gt; 0 &amp; a &amp; b &#39; &amp;bogus",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Sanitize(tc.html); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com", true},
+		{"HTTP://example.com", true},
+		{"mailto:a@example.com", true},
+		{"/post/1", true},
+		{"post/1?a=b:c", true},
+		{"#top", true},
+		{"", false},
+		{"javascript:alert(1)", false},
+		{"vbscript:msgbox(1)", false},
+		{"data:text/html,x", false},
+		{"java\nscript:alert(1)", false},
+		{"\x00javascript:alert(1)", false},
+	}
+
+	for _, tc := range tests {
+		if got := SafeURL(tc.url); got != tc.want {
+			t.Errorf("SafeURL(%q) = %t; want %t", tc.url, got, tc.want)
+		}
+	}
+}
+
+// nesting returns how deeply the elements named tag nest in html.
+func nesting(html, tag string) int {
+	depth, max := 0, 0
+	for i := 0; i < len(html); i++ {
+		switch {
+		case strings.HasPrefix(html[i:], "<"+tag+">") || strings.HasPrefix(html[i:], "<"+tag+" "):
+			depth++
+			if depth > max {
+				max = depth
+			}
+		case strings.HasPrefix(html[i:], "</"+tag+">"):
+			depth--
+		}
+	}
+	return max
+}
@@ -0,0 +1,113 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// allowedTags lists the elements Sanitize keeps, and whether they are void
+// elements without a closing tag.
+var allowedTags = map[string]bool{
+	"p": false, "br": true, "hr": true,
+	"h1": false, "h2": false, "h3": false, "h4": false, "h5": false, "h6": false,
+	"em": false, "strong": false, "code": false, "pre": false, "blockquote": false,
+	"ul": false, "ol": false, "li": false, "a": false,
+}
+
+var (
+	entityRX = regexp.MustCompile(`^&(#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	tagRX    = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[a-zA-Z_:][-a-zA-Z0-9_:.]*(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*)\s*/?>`)
+	attrRX   = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?`)
+)
+
+// Sanitize keeps only allowlisted elements of s, without any attribute but
+// the href of links to safe URLs. Everything else is escaped so that it shows
+// as text, and elements left open are closed at the end.
+func Sanitize(s string) string {
+	var b strings.Builder
+	var open []string
+
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '<':
+			m := tagRX.FindStringSubmatch(s[i:])
+			if m == nil {
+				b.WriteString("&lt;")
+				i++
+				continue
+			}
+			name := strings.ToLower(m[2])
+			void, ok := allowedTags[name]
+			switch {
+			case !ok:
+				b.WriteString(html.EscapeString(m[0]))
+			case m[1] == "/":
+				open = closeTag(&b, open, name)
+			case void:
+				b.WriteString("<" + name + ">")
+			default:
+				b.WriteString("<" + name + sanitizeAttrs(name, m[3]) + ">")
+				open = append(open, name)
+			}
+			i += len(m[0])
+
+		case '>':
+			b.WriteString("&gt;")
+			i++
+
+		case '&':
+			if m := entityRX.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+			} else {
+				b.WriteString("&amp;")
+				i++
+			}
+
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+
+	for j := len(open) - 1; j >= 0; j-- {
+		b.WriteString("</" + open[j] + ">")
+	}
+	return b.String()
+}
+
+// closeTag closes the innermost open element called name along with any
+// opened inside it. A closing tag without a matching element is dropped.
+func closeTag(b *strings.Builder, open []string, name string) []string {
+	for j := len(open) - 1; j >= 0; j-- {
+		if open[j] != name {
+			continue
+		}
+		for k := len(open) - 1; k >= j; k-- {
+			b.WriteString("</" + open[k] + ">")
+		}
+		return open[:j]
+	}
+	return open
+}
+
+func sanitizeAttrs(tag, attrs string) string {
+	if tag != "a" {
+		return ""
+	}
+	for _, m := range attrRX.FindAllStringSubmatch(attrs, -1) {
+		if strings.ToLower(m[1]) != "href" {
+			continue
+		}
+		value := m[2]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		value = strings.TrimSpace(html.UnescapeString(value))
+		if SafeURL(value) {
+			return ` href="` + html.EscapeString(value) + `" rel="nofollow noopener"`
+		}
+	}
+	return ""
+}
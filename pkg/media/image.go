@@ -0,0 +1,227 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	// ThumbnailSize bounds the width and height of generated thumbnails.
+	ThumbnailSize = 400
+	// maxPixels rejects images that are small on disk but would take an
+	// unreasonable amount of memory to decode.
+	maxPixels   = 40 << 20
+	jpegQuality = 90
+)
+
+var (
+	ErrTooLarge    = errors.New("media: image too large")
+	ErrUnsupported = errors.New("media: unsupported image type")
+)
+
+// Image is an upload that passed validation, re-encoded without metadata.
+type Image struct {
+	// Name is derived from the re-encoded content, e.g. "<sha256>.png".
+	Name      string
+	Data      []byte
+	Thumbnail []byte
+}
+
+// ThumbnailName returns the name the thumbnail of the image called name is
+// stored under. JPEG thumbnails stay JPEG; the others become PNG.
+func ThumbnailName(name string) string {
+	ext := path.Ext(name)
+	thumbExt := ".png"
+	if ext == ".jpg" {
+		thumbExt = ".jpg"
+	}
+	return strings.TrimSuffix(name, ext) + "_thumb" + thumbExt
+}
+
+// Process reads an uploaded image of at most maxBytes, checks by its content
+// that it is a JPEG, PNG or GIF and re-encodes it. Re-encoding drops EXIF and
+// other metadata, including the JPEG orientation tag.
+func Process(r io.Reader, maxBytes int64) (*Image, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	var ext string
+	switch http.DetectContentType(raw) {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/png":
+		ext = ".png"
+	case "image/gif":
+		ext = ".gif"
+	default:
+		return nil, ErrUnsupported
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	var data bytes.Buffer
+	var first image.Image
+	switch ext {
+	case ".gif":
+		// Every frame is decoded into an image of up to the full size, so
+		// the limit applies to all of them together.
+		frames, err := gifFrames(raw)
+		if err != nil {
+			return nil, ErrUnsupported
+		}
+		if frames*cfg.Width*cfg.Height > maxPixels {
+			return nil, ErrTooLarge
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(raw))
+		if err != nil || len(g.Image) == 0 {
+			return nil, ErrUnsupported
+		}
+		if err = gif.EncodeAll(&data, g); err != nil {
+			return nil, err
+		}
+		first = g.Image[0]
+	default:
+		img, _, err := image.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, ErrUnsupported
+		}
+		if err = encode(&data, img, ext); err != nil {
+			return nil, err
+		}
+		first = img
+	}
+
+	sum := sha256.Sum256(data.Bytes())
+	name := hex.EncodeToString(sum[:]) + ext
+
+	var thumb bytes.Buffer
+	if err = encode(&thumb, thumbnail(first, ThumbnailSize), path.Ext(ThumbnailName(name))); err != nil {
+		return nil, err
+	}
+
+	return &Image{Name: name, Data: data.Bytes(), Thumbnail: thumb.Bytes()}, nil
+}
+
+var errBadGIF = errors.New("media: malformed GIF")
+
+// gifFrames counts the frames of a GIF by walking its blocks, without
+// decompressing any of them.
+func gifFrames(raw []byte) (int, error) {
+	// The header and logical screen descriptor take 13 bytes, followed by
+	// the global color table if there is one.
+	const screenEnd = 13
+	if len(raw) < screenEnd {
+		return 0, errBadGIF
+	}
+	pos := screenEnd + colorTableSize(raw[10])
+
+	frames := 0
+	for pos < len(raw) {
+		switch raw[pos] {
+		case 0x21: // extension: a label, then data sub-blocks
+			pos += 2
+		case 0x2c: // image descriptor, optional local color table, LZW code size
+			if pos+10 > len(raw) {
+				return 0, errBadGIF
+			}
+			pos += 10 + colorTableSize(raw[pos+9]) + 1
+			frames++
+		case 0x3b: // trailer
+			return frames, nil
+		default:
+			return 0, errBadGIF
+		}
+
+		// Skip the data sub-blocks, each prefixed by its length, up to the
+		// empty one that ends them.
+		for {
+			if pos >= len(raw) {
+				return 0, errBadGIF
+			}
+			n := int(raw[pos])
+			pos += 1 + n
+			if n == 0 {
+				break
+			}
+		}
+	}
+	return 0, errBadGIF
+}
+
+// colorTableSize returns the length in bytes of the color table announced
+// by the packed fields byte of a screen or image descriptor.
+func colorTableSize(fields byte) int {
+	if fields&0x80 == 0 {
+		return 0
+	}
+	return 3 << (fields&0x07 + 1)
+}
+
+func encode(w io.Writer, img image.Image, ext string) error {
+	if ext == ".jpg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return png.Encode(w, img)
+}
+
+// thumbnail scales src down to fit within size×size, averaging the source
+// pixels behind every thumbnail pixel. Smaller images are returned as is.
+func thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA64(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
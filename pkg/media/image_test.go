@@ -0,0 +1,217 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+const testMaxBytes = 10 << 20
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeGIF encodes frames of w×h pixels each on a screen of the given
+// size.
+func encodeGIF(t *testing.T, frames, w, h, screenW, screenH int) []byte {
+	t.Helper()
+	g := &gif.GIF{Config: image.Config{ColorModel: color.Palette(palette.Plan9), Width: screenW, Height: screenH}}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9)
+		frame.SetColorIndex(0, 0, uint8(i))
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// hugePNG returns a PNG whose header claims w×h pixels but which holds no
+// pixel data.
+func hugePNG(w, h uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	ihdr[8], ihdr[9] = 8, 2 // 8-bit RGB
+
+	buf := bytes.NewBufferString("\x89PNG\r\n\x1a\n")
+	binary.Write(buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, testImage(40, 30), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantExt   string
+		wantData  string
+		wantThumb string
+		wantW     int
+		wantH     int
+	}{
+		{"PNG", encodePNG(t, testImage(40, 30)), ".png", "png", "png", 40, 30},
+		{"JPEG", jpg.Bytes(), ".jpg", "jpeg", "jpeg", 40, 30},
+		{"GIF", encodeGIF(t, 3, 20, 10, 20, 10), ".gif", "gif", "png", 20, 10},
+		{"Wide PNG", encodePNG(t, testImage(800, 200)), ".png", "png", "png", ThumbnailSize, ThumbnailSize / 4},
+		{"Tall PNG", encodePNG(t, testImage(100, 1000)), ".png", "png", "png", ThumbnailSize / 10, ThumbnailSize},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := Process(bytes.NewReader(tc.data), testMaxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(img.Name, tc.wantExt) || len(img.Name) != 64+len(tc.wantExt) {
+				t.Errorf("got name %q; want a SHA-256 with %s", img.Name, tc.wantExt)
+			}
+			if _, format, err := image.DecodeConfig(bytes.NewReader(img.Data)); err != nil || format != tc.wantData {
+				t.Errorf("got data in %q, %v; want %s", format, err, tc.wantData)
+			}
+
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Thumbnail))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tc.wantThumb {
+				t.Errorf("got thumbnail in %q; want %s", format, tc.wantThumb)
+			}
+			if cfg.Width != tc.wantW || cfg.Height != tc.wantH {
+				t.Errorf("got thumbnail of %d×%d; want %d×%d", cfg.Width, cfg.Height, tc.wantW, tc.wantH)
+			}
+		})
+	}
+}
+
+func TestProcessKeepsGIFFrames(t *testing.T) {
+	img, err := Process(bytes.NewReader(encodeGIF(t, 3, 20, 10, 20, 10)), testMaxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 3 {
+		t.Errorf("got %d frames; want 3", len(g.Image))
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	small := encodePNG(t, testImage(4, 4))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		maxBytes int64
+		want     error
+	}{
+		{"Too many bytes", small, int64(len(small)) - 1, ErrTooLarge},
+		{"Too many pixels", hugePNG(10000, 10000), testMaxBytes, ErrTooLarge},
+		{
+			// One frame of the full screen is within the limit, but
+			// decoding every frame is not.
+			name:     "Too many GIF frames",
+			data:     encodeGIF(t, maxPixels/(2000*2000)+1, 1, 1, 2000, 2000),
+			maxBytes: testMaxBytes,
+			want:     ErrTooLarge,
+		},
+		{"Text", []byte("hello, world"), testMaxBytes, ErrUnsupported},
+		{"SVG", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), testMaxBytes, ErrUnsupported},
+		{"Truncated PNG", small[:len(small)/2], testMaxBytes, ErrUnsupported},
+		{"PNG header only", small[:8], testMaxBytes, ErrUnsupported},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Process(bytes.NewReader(tc.data), tc.maxBytes); !errors.Is(err, tc.want) {
+				t.Errorf("got %v; want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestGIFFrames(t *testing.T) {
+	many := encodeGIF(t, 12, 3, 2, 3, 2)
+	if n, err := gifFrames(many); err != nil || n != 12 {
+		t.Errorf("got %d, %v; want 12 frames", n, err)
+	}
+
+	// A local color table is skipped with the frame it belongs to.
+	g := &gif.GIF{Config: image.Config{ColorModel: color.Palette(palette.Plan9), Width: 4, Height: 4}}
+	for i := 0; i < 2; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 0)
+	}
+	var local bytes.Buffer
+	if err := gif.EncodeAll(&local, g); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := gifFrames(local.Bytes()); err != nil || n != 2 {
+		t.Errorf("local color tables: got %d, %v; want 2 frames", n, err)
+	}
+
+	bad := [][]byte{
+		many[:10],
+		many[:len(many)-1],
+		append(append([]byte{}, many[:len(many)-1]...), 0x00),
+	}
+	for _, raw := range bad {
+		if _, err := gifFrames(raw); !errors.Is(err, errBadGIF) {
+			t.Errorf("got %v for %d bytes; want %v", err, len(raw), errBadGIF)
+		}
+	}
+}
+
+func TestThumbnailName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"abc.jpg", "abc_thumb.jpg"},
+		{"abc.png", "abc_thumb.png"},
+		{"abc.gif", "abc_thumb.png"},
+	}
+
+	for _, tc := range tests {
+		if got := ThumbnailName(tc.name); got != tc.want {
+			t.Errorf("ThumbnailName(%q) = %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package media validates uploaded images and keeps them in a
+// content-addressed store.
+package media
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Storage keeps media files by name. Names are content-addressed, so saving
+// a name that already exists can be skipped.
+type Storage interface {
+	Save(name string, data []byte) error
+	Open(name string) (fs.File, error)
+}
+
+// Disk stores media files in a single local directory.
+type Disk struct {
+	dir string
+}
+
+// NewDisk returns a Storage writing to dir, which is created if missing.
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Disk{dir: dir}, nil
+}
+
+func (d *Disk) Save(name string, data []byte) error {
+	if !validName(name) {
+		return fs.ErrInvalid
+	}
+	path := filepath.Join(d.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	// Writing to a temporary file first keeps a half-written file from ever
+	// being served under the final name.
+	tmp, err := os.CreateTemp(d.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (d *Disk) Open(name string) (fs.File, error) {
+	if !validName(name) {
+		return nil, fs.ErrNotExist
+	}
+	f, err := os.Open(filepath.Join(d.dir, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fs.ErrNotExist
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// validName accepts plain file names only, so that a name can never point
+// outside the storage directory or at a temporary file.
+func validName(name string) bool {
+	return fs.ValidPath(name) && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
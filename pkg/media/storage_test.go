@@ -0,0 +1,64 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDisk(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "media")
+	d, err := NewDisk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Save("a.png", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	// Names are content-addressed, so a second save is skipped.
+	if err = d.Save("a.png", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := d.Open("a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "first" {
+		t.Errorf("got %q, %v; want %q", data, err, "first")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files; want no temporary files left", len(entries))
+	}
+
+	if _, err = d.Open("missing.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v; want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestDiskInvalidNames(t *testing.T) {
+	d, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"", ".", "..", "../a.png", "sub/a.png", `sub\a.png`, "/a.png", ".upload-1"} {
+		if err := d.Save(name, []byte("x")); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Save(%q): got %v; want %v", name, err, fs.ErrInvalid)
+		}
+		if _, err := d.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Open(%q): got %v; want %v", name, err, fs.ErrNotExist)
+		}
+	}
+}
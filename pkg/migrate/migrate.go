@@ -0,0 +1,311 @@
+// Package migrate applies numbered SQL migrations and records them in a
+// schema_migrations table.
+//
+// Migrations are read from pairs of files named NNNN_name.up.sql and
+// NNNN_name.down.sql. Each one runs in its own transaction together with
+// the update of schema_migrations, so a failing script leaves the database
+// at the previous version.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBehind = errors.New("migrate: database schema is behind")
+	ErrAhead  = errors.New("migrate: database schema is newer than this build")
+	ErrNoDown = errors.New("migrate: migration cannot be reverted")
+)
+
+var fileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration together with whether it has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// dollar is set for drivers that number their bind parameters.
+	dollar bool
+}
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// DollarPlaceholders makes the Migrator write its own statements with $1,
+// $2, ... bind parameters, as PostgreSQL drivers expect, instead of ?.
+func DollarPlaceholders(m *Migrator) {
+	m.dollar = true
+}
+
+// New reads the migrations in the root of files. Versions must be unique,
+// and every migration needs an up script.
+func New(db *sql.DB, files fs.FS, opts ...Option) (*Migrator, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, name := range names {
+		m := fileRX.FindStringSubmatch(path.Base(name))
+		if m == nil {
+			return nil, fmt.Errorf("migrate: unexpected file name %q", name)
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrator := &Migrator{db: db}
+	for _, opt := range opts {
+		opt(migrator)
+	}
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d has no up script", mig.Version)
+		}
+		migrator.migrations = append(migrator.migrations, *mig)
+	}
+	sort.Slice(migrator.migrations, func(i, j int) bool {
+		return migrator.migrations[i].Version < migrator.migrations[j].Version
+	})
+	return migrator, nil
+}
+
+// Latest returns the version of the newest migration, or 0 if there are none.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the newest applied version, or 0 for a database that has
+// never been migrated.
+func (m *Migrator) Version() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Check returns ErrBehind if migrations are pending and ErrAhead if the
+// database has migrations this build does not know about.
+func (m *Migrator) Check() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for v := range applied {
+		if m.find(v) < 0 {
+			return fmt.Errorf("%w: version %d is applied, this build knows up to %d", ErrAhead, v, m.Latest())
+		}
+	}
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			return fmt.Errorf("%w: version %d (%s) is not applied", ErrBehind, mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// Status lists every known migration in order.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		statuses[i] = Status{Migration: mig, Applied: ok, AppliedAt: at}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up() (int, error) {
+	return m.To(m.Latest())
+}
+
+// Down reverts the newest applied migration and returns how many were
+// reverted, which is 0 for a database that has never been migrated.
+func (m *Migrator) Down() (int, error) {
+	version, err := m.Version()
+	if err != nil || version == 0 {
+		return 0, err
+	}
+	i := m.find(version)
+	if i < 0 {
+		return 0, fmt.Errorf("%w: version %d is applied, this build knows up to %d", ErrAhead, version, m.Latest())
+	}
+	if err := m.revert(m.migrations[i]); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// To migrates up or down until exactly the migrations up to version are
+// applied, returning how many were applied or reverted.
+func (m *Migrator) To(version int) (int, error) {
+	if version != 0 && m.find(version) < 0 {
+		return 0, fmt.Errorf("migrate: unknown version %d", version)
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	for v := range applied {
+		if v > version && m.find(v) < 0 {
+			return 0, fmt.Errorf("%w: version %d is applied, this build knows up to %d", ErrAhead, v, m.Latest())
+		}
+	}
+
+	n := 0
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; ok && mig.Version > version {
+			if err := m.revert(mig); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+			if err := m.apply(mig); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Migrator) apply(mig Migration) error {
+	return m.run(mig, mig.Up, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, mig.Version, mig.Name, time.Now().UTC())
+}
+
+func (m *Migrator) revert(mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("%w: version %d (%s) has no down script", ErrNoDown, mig.Version, mig.Name)
+	}
+	return m.run(mig, mig.Down, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+}
+
+// run executes script and the bookkeeping statement in one transaction.
+func (m *Migrator) run(mig Migration, script, record string, args ...any) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("migrate: %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.Exec(m.bind(record), args...); err != nil {
+		return fmt.Errorf("migrate: %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return tx.Commit()
+}
+
+// bind rewrites the ? parameters of query for the driver.
+func (m *Migrator) bind(query string) string {
+	if !m.dollar {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// applied returns the applied versions and when they were applied, creating
+// schema_migrations if needed.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) find(version int) int {
+	for i, mig := range m.migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,249 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testFiles holds three migrations, each creating a table its down script
+// drops.
+var testFiles = fstest.MapFS{
+	"0001_users.up.sql":      {Data: []byte(`CREATE TABLE users (id INTEGER PRIMARY KEY);`)},
+	"0001_users.down.sql":    {Data: []byte(`DROP TABLE users;`)},
+	"0002_posts.up.sql":      {Data: []byte(`CREATE TABLE posts (id INTEGER PRIMARY KEY); INSERT INTO posts VALUES (1);`)},
+	"0002_posts.down.sql":    {Data: []byte(`DROP TABLE posts;`)},
+	"0003_tags.up.sql":       {Data: []byte(`CREATE TABLE tags (id INTEGER PRIMARY KEY);`)},
+	"0003_tags.down.sql":     {Data: []byte(`DROP TABLE tags;`)},
+	"README.md":              {Data: []byte(`not a migration`)},
+	"nested/0009_x.up.sql":   {Data: []byte(`not read`)},
+	"nested/0009_x.down.sql": {Data: []byte(`not read`)},
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// subset returns the files of testFiles for the given versions.
+func subset(names ...string) fstest.MapFS {
+	files := fstest.MapFS{}
+	for _, name := range names {
+		files[name] = testFiles[name]
+	}
+	return files
+}
+
+func newMigrator(t *testing.T, db *sql.DB, files fstest.MapFS) *Migrator {
+	t.Helper()
+	m, err := New(db, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func wantVersion(t *testing.T, m *Migrator, want int) {
+	t.Helper()
+	if got, err := m.Version(); err != nil || got != want {
+		t.Errorf("got version %d, %v; want %d", got, err, want)
+	}
+}
+
+func hasTable(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "Unexpected file name",
+			files: fstest.MapFS{"0001_users.sql": {Data: []byte(`SELECT 1;`)}},
+		},
+		{
+			name: "Version used twice",
+			files: fstest.MapFS{
+				"0001_users.up.sql": {Data: []byte(`SELECT 1;`)},
+				"0001_posts.up.sql": {Data: []byte(`SELECT 1;`)},
+			},
+		},
+		{
+			name:  "Missing up script",
+			files: fstest.MapFS{"0001_users.down.sql": {Data: []byte(`SELECT 1;`)}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := New(openDB(t), tc.files); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+
+	m, err := New(openDB(t), fstest.MapFS{})
+	if err != nil || m.Latest() != 0 {
+		t.Errorf("got latest %v, %v; want 0 for no migrations", m, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := openDB(t)
+	m := newMigrator(t, db, testFiles)
+
+	if m.Latest() != 3 {
+		t.Fatalf("got latest %d; want 3", m.Latest())
+	}
+	wantVersion(t, m, 0)
+	if err := m.Check(); !errors.Is(err, ErrBehind) {
+		t.Errorf("got %v; want %v", err, ErrBehind)
+	}
+	if n, err := m.Down(); err != nil || n != 0 {
+		t.Errorf("down from 0: got %d, %v; want nothing reverted", n, err)
+	}
+
+	if n, err := m.Up(); err != nil || n != 3 {
+		t.Fatalf("up: got %d, %v; want 3 applied", n, err)
+	}
+	wantVersion(t, m, 3)
+	if err := m.Check(); err != nil {
+		t.Errorf("got %v; want nil", err)
+	}
+	if n, err := m.Up(); err != nil || n != 0 {
+		t.Errorf("up again: got %d, %v; want nothing applied", n, err)
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || statuses[1].Name != "posts" || !statuses[1].Applied || statuses[1].AppliedAt.IsZero() {
+		t.Errorf("got %+v; want 3 applied migrations", statuses)
+	}
+
+	if n, err := m.Down(); err != nil || n != 1 {
+		t.Fatalf("down: got %d, %v; want 1 reverted", n, err)
+	}
+	wantVersion(t, m, 2)
+	if hasTable(t, db, "tags") {
+		t.Error("got table tags; want it dropped")
+	}
+	if err := m.Check(); !errors.Is(err, ErrBehind) {
+		t.Errorf("got %v; want %v", err, ErrBehind)
+	}
+	if statuses, _ := m.Status(); statuses[2].Applied {
+		t.Errorf("got %+v; want 0003 pending", statuses[2])
+	}
+
+	if n, err := m.To(1); err != nil || n != 1 {
+		t.Errorf("to 1: got %d, %v; want 1 reverted", n, err)
+	}
+	wantVersion(t, m, 1)
+	if n, err := m.To(3); err != nil || n != 2 {
+		t.Errorf("to 3: got %d, %v; want 2 applied", n, err)
+	}
+	wantVersion(t, m, 3)
+	if n, err := m.To(0); err != nil || n != 3 {
+		t.Errorf("to 0: got %d, %v; want 3 reverted", n, err)
+	}
+	wantVersion(t, m, 0)
+	for _, table := range []string{"users", "posts", "tags"} {
+		if hasTable(t, db, table) {
+			t.Errorf("got table %s; want every migration reverted", table)
+		}
+	}
+
+	if _, err := m.To(7); err == nil {
+		t.Error("to 7: got no error for an unknown version")
+	}
+}
+
+func TestAhead(t *testing.T) {
+	db := openDB(t)
+	if _, err := newMigrator(t, db, testFiles).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An older build knows only the first two migrations.
+	m := newMigrator(t, db, subset("0001_users.up.sql", "0001_users.down.sql", "0002_posts.up.sql", "0002_posts.down.sql"))
+	if err := m.Check(); !errors.Is(err, ErrAhead) {
+		t.Errorf("check: got %v; want %v", err, ErrAhead)
+	}
+	if _, err := m.Up(); !errors.Is(err, ErrAhead) {
+		t.Errorf("up: got %v; want %v", err, ErrAhead)
+	}
+	if _, err := m.Down(); !errors.Is(err, ErrAhead) {
+		t.Errorf("down: got %v; want %v", err, ErrAhead)
+	}
+	if _, err := m.To(1); !errors.Is(err, ErrAhead) {
+		t.Errorf("to 1: got %v; want %v", err, ErrAhead)
+	}
+	wantVersion(t, m, 3)
+}
+
+func TestNoDown(t *testing.T) {
+	db := openDB(t)
+	m := newMigrator(t, db, subset("0001_users.up.sql", "0001_users.down.sql", "0002_posts.up.sql"))
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Down(); !errors.Is(err, ErrNoDown) {
+		t.Errorf("down: got %v; want %v", err, ErrNoDown)
+	}
+	if n, err := m.To(0); !errors.Is(err, ErrNoDown) || n != 0 {
+		t.Errorf("to 0: got %d, %v; want %v before reverting anything", n, err, ErrNoDown)
+	}
+	wantVersion(t, m, 2)
+}
+
+func TestFailingScript(t *testing.T) {
+	db := openDB(t)
+	files := subset("0001_users.up.sql", "0003_tags.up.sql")
+	// The table is created before the failing statement; both must be
+	// rolled back.
+	files["0002_broken.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE broken (id INTEGER); INSERT INTO missing VALUES (1);`)}
+	m := newMigrator(t, db, files)
+
+	n, err := m.Up()
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if n != 1 {
+		t.Errorf("got %d applied; want only the migration before the broken one", n)
+	}
+	wantVersion(t, m, 1)
+	if hasTable(t, db, "broken") {
+		t.Error("got table broken; want the failed migration rolled back")
+	}
+	if hasTable(t, db, "tags") {
+		t.Error("got table tags; want migrations after the broken one left alone")
+	}
+}
+
+func TestDollarPlaceholders(t *testing.T) {
+	m, err := New(openDB(t), fstest.MapFS{}, DollarPlaceholders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.bind(`INSERT INTO t VALUES (?, ?, ?)`), `INSERT INTO t VALUES ($1, $2, $3)`; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,28 @@
+// Package token creates one-time secrets for links sent by email. Only the
+// hash of a token is meant to be stored, so a leaked database cannot be used
+// to redeem outstanding links.
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const length = 32
+
+// New returns a random URL-safe token.
+func New() (string, error) {
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns the value to store and look up for token.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}